@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/cosmos-toolkit/cli/internal/catalog"
@@ -15,7 +14,7 @@ func main() {
 	}
 
 	if err := cli.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		cli.PrintError(err)
 		os.Exit(1)
 	}
 }
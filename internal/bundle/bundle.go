@@ -0,0 +1,570 @@
+// Package bundle exports selected templates and packages from the local
+// cache into a tar.gz archive, and imports such an archive into the cache of
+// another machine so that init and pkg work without network access.
+//
+// Archive layout:
+//
+//	bundle.json                      Manifest (always the first entry)
+//	templates/manifest.yaml          templates repo manifest, when cached
+//	templates/<name>/...             one directory per template
+//	packages/manifest.yaml           packages repo manifest (copy_deps, go_get)
+//	packages/pkg/<name>/...          one directory per package
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cosmos-toolkit/cli/internal/loader"
+	"github.com/cosmos-toolkit/cli/internal/resolver"
+	"github.com/cosmos-toolkit/cli/internal/rules"
+	"github.com/cosmos-toolkit/cli/internal/writer"
+	"gopkg.in/yaml.v3"
+)
+
+// FormatVersion is bumped when the archive layout changes incompatibly.
+const FormatVersion = 1
+
+const manifestName = "bundle.json"
+
+// Manifest describes the content of a bundle.
+type Manifest struct {
+	Version   int       `json:"version" yaml:"version"`
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
+	Templates []Entry   `json:"templates" yaml:"templates"`
+	Packages  []Entry   `json:"packages" yaml:"packages"`
+}
+
+// Entry is one template or package, with the commit it was exported from.
+type Entry struct {
+	Name   string `json:"name" yaml:"name"`
+	Commit string `json:"commit,omitempty" yaml:"commit,omitempty"`
+}
+
+// ExportOpts selects what goes into a bundle.
+type ExportOpts struct {
+	Templates []string
+	Packages  []string
+}
+
+// packagesManifest is the subset of the packages manifest.yaml needed to
+// follow copy_deps.
+type packagesManifest struct {
+	Packages map[string]struct {
+		CopyDeps []string `yaml:"copy_deps"`
+	} `yaml:"packages"`
+}
+
+// Export resolves the selected templates and packages into the cache (fetching
+// them when needed and allowed) and writes them to w as a gzipped tar. The
+// copy_deps of each package, and the external templates a template extends,
+// are included as well.
+func Export(w io.Writer, opts ExportOpts) (*Manifest, error) {
+	m := &Manifest{Version: FormatVersion, CreatedAt: time.Now().UTC(), Templates: []Entry{}, Packages: []Entry{}}
+
+	type source struct{ dir, prefix string }
+	var sources []source
+
+	var templatesRepo string
+	templates := append([]string(nil), opts.Templates...)
+	for i := 0; i < len(templates); i++ {
+		name := templates[i]
+		if err := rules.ValidateTemplateName(name); err != nil {
+			return nil, fmt.Errorf("template %q: %w", name, err)
+		}
+		dir, err := resolver.Resolve(name)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		if t, err := loader.LoadFromPath(dir); err == nil && t.Extends != "" &&
+			rules.ValidateType(t.Extends) != nil && !containsName(templates, t.Extends) {
+			templates = append(templates, t.Extends)
+		}
+		templatesRepo = filepath.Dir(dir)
+		m.Templates = append(m.Templates, Entry{Name: name, Commit: resolver.EntryCommit(resolver.KindTemplate, name)})
+		sources = append(sources, source{dir, "templates/" + name})
+	}
+
+	var packagesRepo string
+	if len(opts.Packages) > 0 {
+		repo, err := resolver.ResolvePackagesRepo()
+		if err != nil {
+			return nil, fmt.Errorf("packages: %w", err)
+		}
+		packagesRepo = repo
+		names, err := withCopyDeps(filepath.Join(repo, "manifest.yaml"), opts.Packages)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			dir := filepath.Join(repo, "pkg", name)
+			if _, err := os.Stat(dir); err != nil {
+				return nil, fmt.Errorf("package %q not found in %s", name, filepath.Join(repo, "pkg"))
+			}
+			m.Packages = append(m.Packages, Entry{Name: name, Commit: resolver.EntryCommit(resolver.KindPackage, name)})
+			sources = append(sources, source{dir, "packages/pkg/" + name})
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(tw, manifestName, data, 0644); err != nil {
+		return nil, err
+	}
+	if templatesRepo != "" {
+		if err := addOptionalFile(tw, filepath.Join(templatesRepo, "manifest.yaml"), "templates/manifest.yaml"); err != nil {
+			return nil, err
+		}
+	}
+	if packagesRepo != "" {
+		if err := addOptionalFile(tw, filepath.Join(packagesRepo, "manifest.yaml"), "packages/manifest.yaml"); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range sources {
+		if err := addDir(tw, s.dir, s.prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return m, nil
+}
+
+// withCopyDeps returns names plus their copy_deps, deduplicated, in order.
+func withCopyDeps(manifestPath string, names []string) ([]string, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read packages manifest: %w", err)
+	}
+	var pm packagesManifest
+	if err := yaml.Unmarshal(data, &pm); err != nil {
+		return nil, fmt.Errorf("failed to parse packages manifest: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, name := range names {
+		meta, ok := pm.Packages[name]
+		if !ok {
+			return nil, fmt.Errorf("package %q not found in manifest", name)
+		}
+		for _, n := range append([]string{name}, meta.CopyDeps...) {
+			if !seen[n] {
+				seen[n] = true
+				out = append(out, n)
+			}
+		}
+	}
+	return out, nil
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte, mode int64) error {
+	hdr := &tar.Header{Name: name, Mode: mode, Size: int64(len(data)), ModTime: time.Now(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func addOptionalFile(tw *tar.Writer, src, name string) error {
+	data, err := os.ReadFile(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writeFile(tw, name, data, 0644)
+}
+
+// addDir adds the regular files and symlinks of dir under prefix, skipping
+// .git. A symlink must point inside dir.
+func addDir(tw *tar.Writer, dir, prefix string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		name := path.Join(prefix, rel)
+		if d.Type()&fs.ModeSymlink != 0 {
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			if !writer.LinkInside(rel, link) {
+				return fmt.Errorf("symlink %s points outside %s: %s", p, dir, link)
+			}
+			hdr := &tar.Header{Name: name, Linkname: filepath.ToSlash(link), Mode: 0777, ModTime: time.Now(), Typeflag: tar.TypeSymlink}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return fmt.Errorf("failed to write %s: %w", name, err)
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("cannot bundle %s: not a regular file or symlink", p)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return writeFile(tw, name, data, int64(info.Mode().Perm()))
+	})
+}
+
+// ImportResult reports what Import did.
+type ImportResult struct {
+	Manifest *Manifest `json:"manifest" yaml:"manifest"`
+	Imported []string  `json:"imported" yaml:"imported"`
+	Skipped  []string  `json:"skipped" yaml:"skipped"`
+}
+
+// Import reads a bundle from r and seeds the cache with its templates and
+// packages. Entries already in the cache are skipped unless force is set.
+// manifest.yaml files are only written (or merged) when the cache is not a
+// git checkout, so that a later 'cosmos update' still pulls cleanly.
+func Import(r io.Reader, force bool) (*ImportResult, error) {
+	root, err := resolver.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	staging, err := os.MkdirTemp(root, ".bundle-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	m, err := extract(r, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kind := range []string{resolver.KindTemplate, resolver.KindPackage} {
+		unlock, err := resolver.LockCache(kind)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	res := &ImportResult{Manifest: m, Imported: []string{}, Skipped: []string{}}
+	kinds := []struct {
+		kind    string
+		dir     string
+		entries []Entry
+	}{
+		{resolver.KindTemplate, "templates", m.Templates},
+		{resolver.KindPackage, filepath.Join("packages", "pkg"), m.Packages},
+	}
+	// Every entry is checked before the cache is touched
+	var installs []install
+	seen := make(map[string]bool)
+	for _, k := range kinds {
+		for _, e := range k.entries {
+			label := k.kind + " " + e.Name
+			if seen[label] {
+				continue
+			}
+			seen[label] = true
+			src := filepath.Join(staging, k.dir, e.Name)
+			if info, err := os.Lstat(src); err != nil || !info.IsDir() {
+				return nil, fmt.Errorf("invalid bundle: %s is listed in %s but not in the archive", label, manifestName)
+			}
+			dst, err := resolver.EntryPath(k.kind, e.Name)
+			if err != nil {
+				return nil, err
+			}
+			_, err = os.Lstat(dst)
+			exists := err == nil
+			if exists && !force {
+				res.Skipped = append(res.Skipped, label)
+				continue
+			}
+			installs = append(installs, install{kind: k.kind, name: e.Name, label: label, src: src, dst: dst, replace: exists})
+		}
+	}
+	if err := installEntries(staging, installs); err != nil {
+		return nil, err
+	}
+
+	for _, k := range kinds {
+		var names []string
+		for _, in := range installs {
+			if in.kind == k.kind {
+				res.Imported = append(res.Imported, in.label)
+				names = append(names, in.name)
+			}
+		}
+		if len(k.entries) == 0 {
+			continue
+		}
+		if !resolver.IsGitCheckout(k.kind) {
+			manifestDir := "templates"
+			if k.kind == resolver.KindPackage {
+				manifestDir = "packages"
+			}
+			if err := mergeManifest(filepath.Join(staging, manifestDir, "manifest.yaml"), k.kind); err != nil {
+				return nil, err
+			}
+		}
+		resolver.RecordImported(k.kind, m.CreatedAt, names...)
+	}
+	return res, nil
+}
+
+// install is an entry of a bundle to move from staging into the cache.
+type install struct {
+	kind, name, label string
+	src, dst          string
+	// replace is set when dst exists; old is where it was moved aside.
+	replace bool
+	old     string
+}
+
+// installEntries moves the entries from staging into the cache. The
+// entries they replace are moved aside first, and put back when an install
+// fails, so that a failed import leaves the cache as it was.
+func installEntries(staging string, installs []install) (err error) {
+	var done []install
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(done) - 1; i >= 0; i-- {
+			in := done[i]
+			os.RemoveAll(in.dst)
+			if in.replace {
+				os.Rename(in.old, in.dst)
+			}
+		}
+	}()
+
+	aside := filepath.Join(staging, ".replaced")
+	for i, in := range installs {
+		if in.replace {
+			if err := os.MkdirAll(aside, 0755); err != nil {
+				return err
+			}
+			in.old = filepath.Join(aside, strconv.Itoa(i))
+			if err := os.Rename(in.dst, in.old); err != nil {
+				return fmt.Errorf("failed to replace %s: %w", in.dst, err)
+			}
+		}
+		done = append(done, in)
+		if err := os.MkdirAll(filepath.Dir(in.dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(in.src, in.dst); err != nil {
+			return fmt.Errorf("failed to install %s: %w", in.label, err)
+		}
+	}
+	return nil
+}
+
+// extract unpacks the archive into dir after validating bundle.json and
+// every entry path.
+func extract(r io.Reader, dir string) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a bundle (gzip): %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	var m *Manifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		name := path.Clean(hdr.Name)
+		if m == nil {
+			if name != manifestName {
+				return nil, fmt.Errorf("not a bundle: %s must be the first entry", manifestName)
+			}
+			m = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", manifestName, err)
+			}
+			if m.Version != FormatVersion {
+				return nil, fmt.Errorf("unsupported bundle version %d (this cosmos reads version %d)", m.Version, FormatVersion)
+			}
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeSymlink {
+			continue
+		}
+		if !validEntryPath(name) {
+			return nil, fmt.Errorf("invalid path in bundle: %s", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		// A symlink is only checked against its own path: writing through
+		// one an earlier entry created could land anywhere
+		if err := writer.CheckNoSymlinks(dir, target); err != nil {
+			return nil, fmt.Errorf("invalid path in bundle: %s: %w", name, err)
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			if !writer.LinkInside(entryRel(name), hdr.Linkname) {
+				return nil, fmt.Errorf("symlink %s in bundle points outside its template or package: %s", name, hdr.Linkname)
+			}
+			if err := writer.WriteSymlink(target, hdr.Linkname); err != nil {
+				return nil, fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(hdr.Mode).Perm()|0200)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+	}
+	if m == nil {
+		return nil, fmt.Errorf("not a bundle: empty archive")
+	}
+	for _, e := range append(append([]Entry{}, m.Templates...), m.Packages...) {
+		if e.Name == "" || strings.ContainsAny(e.Name, `/\`) || e.Name == "." || e.Name == ".." {
+			return nil, fmt.Errorf("invalid entry name in %s: %q", manifestName, e.Name)
+		}
+	}
+	return m, nil
+}
+
+// entryRel returns the path of an archive entry inside its template or
+// package: "templates/api/a/b" is "a/b".
+func entryRel(name string) string {
+	rest := strings.TrimPrefix(name, "templates/")
+	if rest == name {
+		rest = strings.TrimPrefix(name, "packages/pkg/")
+	}
+	_, rel, _ := strings.Cut(rest, "/")
+	return rel
+}
+
+func validEntryPath(name string) bool {
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return false
+	}
+	return strings.HasPrefix(name, "templates/") || strings.HasPrefix(name, "packages/")
+}
+
+// mergeManifest adds the entries of the bundled manifest.yaml to the cached
+// one without overwriting existing entries.
+func mergeManifest(src, kind string) error {
+	data, err := os.ReadFile(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	repoPath, err := resolver.RepoPath(kind)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(repoPath, "manifest.yaml")
+
+	existing, err := os.ReadFile(dst)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(repoPath, 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(dst, data, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	var into, from map[string]map[string]any
+	if err := yaml.Unmarshal(existing, &into); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", dst, err)
+	}
+	if err := yaml.Unmarshal(data, &from); err != nil {
+		return fmt.Errorf("failed to parse bundled manifest: %w", err)
+	}
+	if into == nil {
+		into = make(map[string]map[string]any)
+	}
+	keys := make([]string, 0, len(from))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, section := range keys {
+		if into[section] == nil {
+			into[section] = make(map[string]any)
+		}
+		for name, v := range from[section] {
+			if _, ok := into[section][name]; !ok {
+				into[section][name] = v
+			}
+		}
+	}
+	out, err := yaml.Marshal(into)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, out, 0644)
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cosmos-toolkit/cli/internal/resolver"
+)
+
+// testEntry is a file (link == "") or symlink of a test bundle.
+type testEntry struct {
+	name, data, link string
+}
+
+// testBundle returns a bundle of m and entries.
+func testBundle(t *testing.T, m Manifest, entries ...testEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	m.Version = FormatVersion
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(tw, manifestName, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.link == "" {
+			err = writeFile(tw, e.name, []byte(e.data), 0644)
+		} else {
+			err = tw.WriteHeader(&tar.Header{Name: e.name, Linkname: e.link, Typeflag: tar.TypeSymlink, Mode: 0777})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractRejectsWritesThroughSymlinks(t *testing.T) {
+	root := t.TempDir()
+	staging := filepath.Join(root, "cache", "staging")
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Each link stays inside the template on its own; chained, l is the
+	// parent of root
+	b := testBundle(t, Manifest{Templates: []Entry{{Name: "api"}}},
+		testEntry{name: "templates/api/x", link: "."},
+		testEntry{name: "templates/api/x/x/x/x/l", link: "../../../.."},
+		testEntry{name: "templates/api/l/PWNED", data: "pwned"},
+	)
+
+	if _, err := extract(b, staging); err == nil {
+		t.Error("extract succeeded, want an error for the entry below a symlink")
+	}
+	matches, _ := filepath.Glob(filepath.Join(root, "*", "PWNED"))
+	more, _ := filepath.Glob(filepath.Join(root, "PWNED"))
+	if len(matches)+len(more) > 0 {
+		t.Errorf("extract wrote %v outside the staging directory", append(matches, more...))
+	}
+}
+
+func TestExtractKeepsSymlinksInside(t *testing.T) {
+	staging := t.TempDir()
+	b := testBundle(t, Manifest{Templates: []Entry{{Name: "api"}}},
+		testEntry{name: "templates/api/docs/index.md", data: "# Docs\n"},
+		testEntry{name: "templates/api/README.md", link: "docs/index.md"},
+	)
+
+	if _, err := extract(b, staging); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	link, err := os.Readlink(filepath.Join(staging, "templates", "api", "README.md"))
+	if err != nil || link != "docs/index.md" {
+		t.Errorf("README.md links to %q (%v), want docs/index.md", link, err)
+	}
+
+	b = testBundle(t, Manifest{Templates: []Entry{{Name: "web"}}},
+		testEntry{name: "templates/web/up", link: "../api"},
+	)
+	if _, err := extract(b, t.TempDir()); err == nil {
+		t.Error("extract accepted a symlink out of its template")
+	}
+}
+
+// seedCache points the cache at a temporary directory holding the
+// template api, and returns the path of its template.yaml.
+func seedCache(t *testing.T) string {
+	t.Helper()
+	t.Setenv("COSMOS_CACHE_DIR", t.TempDir())
+	dst, err := resolver.EntryPath(resolver.KindTemplate, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dst, "template.yaml")
+	if err := os.WriteFile(p, []byte("name: api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestImportForceReplaces(t *testing.T) {
+	cached := seedCache(t)
+	b := testBundle(t, Manifest{Templates: []Entry{{Name: "api"}}},
+		testEntry{name: "templates/api/template.yaml", data: "name: api\nversion: 2.0.0\n"},
+	)
+
+	res, err := Import(b, true)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(res.Imported) != 1 || len(res.Skipped) != 0 {
+		t.Errorf("Import = %+v, want template api imported", res)
+	}
+	if data, _ := os.ReadFile(cached); string(data) != "name: api\nversion: 2.0.0\n" {
+		t.Errorf("cached template.yaml = %q, want the bundled one", data)
+	}
+}
+
+func TestImportBadBundleLeavesCache(t *testing.T) {
+	cached := seedCache(t)
+	// web is listed but missing from the archive
+	b := testBundle(t, Manifest{Templates: []Entry{{Name: "api"}, {Name: "web"}}},
+		testEntry{name: "templates/api/template.yaml", data: "name: api\nversion: 2.0.0\n"},
+	)
+
+	if _, err := Import(b, true); err == nil {
+		t.Fatal("Import succeeded, want an error for the missing template")
+	}
+	if data, err := os.ReadFile(cached); err != nil || string(data) != "name: api\n" {
+		t.Errorf("cached template.yaml = %q (%v), want it unchanged", data, err)
+	}
+	web, _ := resolver.EntryPath(resolver.KindTemplate, "web")
+	if _, err := os.Stat(web); !os.IsNotExist(err) {
+		t.Errorf("template web is in the cache after a failed import")
+	}
+}
+
+func TestInstallEntriesRestoresOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	staging := filepath.Join(dir, "staging")
+	mkfile := func(p, data string) {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mkfile(filepath.Join(staging, "a", "f"), "new")
+	mkfile(filepath.Join(dir, "cache", "a", "f"), "old")
+
+	installs := []install{
+		{label: "a", src: filepath.Join(staging, "a"), dst: filepath.Join(dir, "cache", "a"), replace: true},
+		{label: "b", src: filepath.Join(staging, "missing"), dst: filepath.Join(dir, "cache", "b")},
+	}
+	if err := installEntries(staging, installs); err == nil {
+		t.Fatal("installEntries succeeded, want an error for b")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "cache", "a", "f")); err != nil || string(data) != "old" {
+		t.Errorf("a/f = %q (%v), want the old content restored", data, err)
+	}
+}
@@ -59,10 +59,10 @@ func (c *Catalog) ListEmbeddedTypes() []string {
 
 // TemplateInfo holds metadata for display in lists.
 type TemplateInfo struct {
-	Type     string
-	Name     string
-	Version  string
-	Features []string
+	Type     string   `json:"type" yaml:"type"`
+	Name     string   `json:"name" yaml:"name"`
+	Version  string   `json:"version" yaml:"version"`
+	Features []string `json:"features" yaml:"features"`
 }
 
 // ListTemplates returns all embedded templates with metadata for listing/discovery.
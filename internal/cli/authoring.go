@@ -0,0 +1,610 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cosmos-toolkit/cli/internal/hooks"
+	"github.com/cosmos-toolkit/cli/internal/lint"
+	"github.com/cosmos-toolkit/cli/internal/loader"
+	"github.com/cosmos-toolkit/cli/internal/renderer"
+	"github.com/cosmos-toolkit/cli/internal/resolver"
+	"github.com/cosmos-toolkit/cli/internal/rules"
+	"github.com/cosmos-toolkit/cli/internal/scaffold"
+	"github.com/cosmos-toolkit/cli/internal/templatetest"
+	"github.com/cosmos-toolkit/cli/internal/writer"
+	"github.com/olekukonko/tablewriter"
+)
+
+// This file holds the commands for template authors (cosmos template ...),
+// which work on a template directory rather than on the registry.
+
+type lintReport struct {
+	OK       bool           `json:"ok" yaml:"ok"`
+	Template string         `json:"template" yaml:"template"`
+	Findings []lint.Finding `json:"findings" yaml:"findings"`
+}
+
+// loadTemplateDir loads the template in dir as an external template named
+// after the directory.
+func loadTemplateDir(dir string) (*templateSource, []byte, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(abs, "template.yaml"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, newError(CodeNotFound, fmt.Errorf("%s is not a template: no template.yaml", dir))
+		}
+		return nil, nil, err
+	}
+	src := &templateSource{name: filepath.Base(abs), fs: renderer.DirFS(abs), dir: abs, external: true}
+	src.template, err = loader.LoadFromBytes(data)
+	return src, data, err
+}
+
+func runTemplateLint(in *invocation) error {
+	dir := in.args[0]
+	findings, err := lintTemplate(dir)
+	if err != nil {
+		return err
+	}
+	failed := lint.HasErrors(findings)
+
+	if structuredOutput() {
+		if findings == nil {
+			findings = []lint.Finding{}
+		}
+		if err := writeStructured(os.Stdout, lintReport{OK: !failed, Template: dir, Findings: findings}); err != nil {
+			return err
+		}
+	} else {
+		errs, warnings := 0, 0
+		for _, f := range findings {
+			mark := yellow + "!" + reset
+			if f.Severity == lint.Error {
+				mark = red + "✗" + reset
+				errs++
+			} else {
+				warnings++
+			}
+			fmt.Printf("  %s %s %s %s\n", mark, f.Location(), dimmed("["+f.Check+"]"), f.Message)
+		}
+		if len(findings) > 0 {
+			fmt.Printf("\n  %s, %s\n\n", plural(errs, "error"), plural(warnings, "warning"))
+		}
+	}
+
+	if failed {
+		return silent(newError(CodeInvalidInput, fmt.Errorf("template %s has errors", dir)))
+	}
+	if !structuredOutput() && len(findings) == 0 {
+		fmt.Printf("%s No problems found in %s\n", green+"✓"+reset, accent(dir))
+	}
+	return nil
+}
+
+// lintTemplate runs every check of package lint on the template in dir.
+// Checks that need the template it extends are skipped, with a warning,
+// when that template cannot be loaded.
+func lintTemplate(dir string) ([]lint.Finding, error) {
+	src, data, err := loadTemplateDir(dir)
+	if data == nil {
+		return nil, err
+	}
+	findings := lint.ValidateSchema(data)
+	if err != nil {
+		// The schema usually says the same, with a line number
+		if !lint.HasErrors(findings) {
+			findings = append(findings, lint.Finding{Severity: lint.Error, Check: lint.CheckTemplateYAML, Path: "template.yaml", Message: err.Error()})
+		}
+		return findings, nil
+	}
+
+	t := lint.Template{Name: src.name, Own: src.template, DefaultPlaceholder: defaultModulePlaceholder}
+	engine, err := renderer.NewEngine(src.template.Files)
+	if err == nil {
+		t.Layers, err = sourceLayers(src, engine)
+	}
+	if err != nil {
+		findings = append(findings, lint.Finding{Severity: lint.Error, Check: lint.CheckTemplateYAML, Path: "template.yaml", Message: err.Error()})
+		return findings, nil
+	}
+	if src.template.Extends == "" {
+		t.Composed = src.template
+	} else if c, err := composeTemplate(src); err != nil {
+		findings = append(findings, lint.Finding{Severity: lint.Warning, Check: lint.CheckTemplateYAML, Path: "template.yaml",
+			Message: fmt.Sprintf("%v; the checks that need the base template are skipped", err)})
+	} else {
+		t.Composed, t.Layers = c.template, c.layers
+	}
+
+	more, err := lint.Check(t)
+	if err != nil {
+		return nil, err
+	}
+	findings = append(findings, more...)
+	lint.Sort(findings)
+	return findings, nil
+}
+
+// testCase is the outcome of one answers file of cosmos template test.
+type testCase struct {
+	Name    string `json:"name" yaml:"name"`
+	Answers string `json:"answers,omitempty" yaml:"answers,omitempty"`
+	// Dir is the generated project, kept with --keep.
+	Dir   string              `json:"dir,omitempty" yaml:"dir,omitempty"`
+	OK    bool                `json:"ok" yaml:"ok"`
+	Steps []templatetest.Step `json:"steps" yaml:"steps"`
+}
+
+type testReport struct {
+	OK       bool       `json:"ok" yaml:"ok"`
+	Template string     `json:"template" yaml:"template"`
+	Cases    []testCase `json:"cases" yaml:"cases"`
+}
+
+// testOptions are the flags of cosmos template test.
+type testOptions struct {
+	golden  string
+	update  bool
+	noHooks bool
+}
+
+// defaultCase names the case run when no answers file is given.
+const defaultCase = "default"
+
+func runTemplateTest(in *invocation) error {
+	dir := in.args[0]
+	opts := testOptions{golden: in.String("golden"), update: in.Bool("update"), noHooks: in.Bool("no-hooks")}
+	if opts.noHooks && in.Bool("trust-hooks") {
+		return newError(CodeUsage, fmt.Errorf("--trust-hooks and --no-hooks cannot be used together"))
+	}
+	if opts.update && opts.golden == "" {
+		return newError(CodeUsage, fmt.Errorf("--update requires --golden <dir>"))
+	}
+	files, err := caseFiles(in.String("answers"), in.args[1:])
+	if err != nil {
+		return err
+	}
+
+	src, _, err := loadTemplateDir(dir)
+	if err != nil {
+		if src == nil {
+			return err
+		}
+		return newError(CodeInvalidInput, fmt.Errorf("failed to load template: %w", err))
+	}
+	comp, err := composeTemplate(src)
+	if err != nil {
+		return err
+	}
+	// The hooks of the template under test are its author's; those of the
+	// external templates it extends run only when trusted, as with init.
+	if !opts.noHooks && !in.Bool("trust-hooks") {
+		for _, s := range comp.externalHooks {
+			if s != src {
+				return newError(CodeUsage, fmt.Errorf("template %s extends %s, whose hooks run shell commands; pass --trust-hooks to run them or --no-hooks to skip them", dir, s.name))
+			}
+		}
+	}
+
+	root, err := os.MkdirTemp("", "cosmos-template-test-")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary directory: %w", err)
+	}
+	if !in.Bool("keep") {
+		defer os.RemoveAll(root)
+	}
+
+	report := testReport{OK: true, Template: dir}
+	for i, file := range files {
+		name := defaultCase
+		if file != "" {
+			name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		status("%s %s\n", dimmed("testing"), name)
+		c := runTestCase(comp, name, file, filepath.Join(root, fmt.Sprintf("%d-%s", i+1, name)), opts)
+		if !in.Bool("keep") {
+			c.Dir = ""
+		}
+		report.OK = report.OK && c.OK
+		report.Cases = append(report.Cases, c)
+	}
+
+	if structuredOutput() {
+		if err := writeStructured(os.Stdout, report); err != nil {
+			return err
+		}
+	} else if err := printTestReport(report); err != nil {
+		return err
+	}
+	if !report.OK {
+		return silent(newError(CodeTestFailed, fmt.Errorf("template %s failed its tests", dir)))
+	}
+	return nil
+}
+
+// caseFiles returns the answers files of the cases: the file or glob
+// pattern of --answers and the files given as arguments (a shell expanding
+// --answers cases/*.yaml passes all but the first as arguments). No file
+// means one case with no answers.
+func caseFiles(pattern string, args []string) ([]string, error) {
+	var files []string
+	if pattern != "" {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, newError(CodeUsage, fmt.Errorf("--answers: %w", err))
+		}
+		if len(matches) == 0 {
+			return nil, newError(CodeNotFound, fmt.Errorf("--answers: no file matches %s", pattern))
+		}
+		files = append(files, matches...)
+	}
+	files = append(files, args...)
+	if len(files) == 0 {
+		return []string{""}, nil
+	}
+	return files, nil
+}
+
+// runTestCase generates the project of one case in caseDir and checks it.
+// A step that cannot run after a failure is skipped.
+func runTestCase(comp *composition, name, file, caseDir string, opts testOptions) testCase {
+	c := testCase{Name: name, Answers: file}
+	projectDir, render := renderTestCase(comp, name, file, caseDir, opts.noHooks)
+	c.Steps = append(c.Steps, render)
+	c.Dir = projectDir
+	rendered := render.Status == templatetest.Pass
+
+	if opts.golden != "" {
+		c.Steps = append(c.Steps, goldenStep(projectDir, filepath.Join(opts.golden, name), opts.update, rendered))
+	}
+
+	switch {
+	case !rendered:
+		for _, s := range []string{templatetest.StepBuild, templatetest.StepVet, templatetest.StepTest} {
+			c.Steps = append(c.Steps, templatetest.Step{Name: s, Status: templatetest.Skip})
+		}
+	case !writer.FileExists(filepath.Join(projectDir, "go.mod")):
+		for _, s := range []string{templatetest.StepBuild, templatetest.StepVet, templatetest.StepTest} {
+			c.Steps = append(c.Steps, templatetest.Step{Name: s, Status: templatetest.Skip, Error: "no go.mod"})
+		}
+	default:
+		env := os.Environ()
+		if resolver.IsOffline() {
+			env = append(env, "GOPROXY=off")
+		}
+		c.Steps = append(c.Steps, templatetest.GoChecks(projectDir, env)...)
+	}
+
+	c.OK = true
+	for _, s := range c.Steps {
+		if s.Status == templatetest.Fail {
+			c.OK = false
+		}
+	}
+	return c
+}
+
+// renderTestCase generates the project of a case as init would, with its
+// hooks unless noHooks. The case's answers may set the project's module
+// and projectName; they default to example.com/<name> and the case name.
+func renderTestCase(comp *composition, name, file, caseDir string, noHooks bool) (string, templatetest.Step) {
+	start := time.Now()
+	step := templatetest.Step{Name: templatetest.StepRender, Status: templatetest.Pass}
+	fail := func(err error, output []string) (string, templatetest.Step) {
+		step.Status = templatetest.Fail
+		step.Error = err.Error()
+		step.Output = output
+		step.DurationMS = time.Since(start).Milliseconds()
+		return "", step
+	}
+
+	answers, err := readAnswers(file)
+	if err != nil {
+		return fail(err, nil)
+	}
+	config := &Config{ProjectName: name, Module: "example.com/" + name, Answers: file, NoHooks: noHooks, NoPrompt: true}
+	if s, ok := answers["projectName"].(string); ok {
+		config.ProjectName = s
+	}
+	if s, ok := answers["module"].(string); ok {
+		config.Module = s
+	}
+	if err := rules.ValidateProjectName(config.ProjectName); err != nil {
+		return fail(fmt.Errorf("%w (set projectName in the answers file)", err), nil)
+	}
+	if err := rules.ValidateModulePath(config.Module); err != nil {
+		return fail(fmt.Errorf("%w (set module in the answers file)", err), nil)
+	}
+	ctx, err := newRenderContext(config, comp.template, answers)
+	if err != nil {
+		return fail(err, nil)
+	}
+	templateHooks, err := renderHooks(comp.template, ctx)
+	if err != nil {
+		return fail(err, nil)
+	}
+
+	projectDir := filepath.Join(caseDir, config.ProjectName)
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		return fail(err, nil)
+	}
+	runHooks := func(stage hooks.Stage, commands []string) error {
+		if noHooks || len(commands) == 0 {
+			return nil
+		}
+		results, ok := hooks.Run(stage, commands, projectDir, hookEnv(ctx, projectDir), nil)
+		if ok {
+			return nil
+		}
+		for _, r := range results {
+			if r.Status == hooks.StatusFailed {
+				step.Output = r.Output
+				return fmt.Errorf("%s hook %q failed: %s", stage, r.Command, r.Error)
+			}
+		}
+		return nil
+	}
+
+	if err := runHooks(hooks.PreRender, templateHooks.PreRender); err != nil {
+		return fail(err, step.Output)
+	}
+	if err := renderer.RenderLayers(comp.layers, ctx, projectDir); err != nil {
+		var output []string
+		var renderErrs renderer.Errors
+		if errors.As(err, &renderErrs) {
+			for _, fe := range renderErrs {
+				output = append(output, fe.Error())
+			}
+			err = fmt.Errorf("%d files failed to render", len(renderErrs))
+		}
+		return fail(err, output)
+	}
+	if err := runHooks(hooks.PostRender, templateHooks.PostRender); err != nil {
+		return fail(err, step.Output)
+	}
+	step.DurationMS = time.Since(start).Milliseconds()
+	return projectDir, step
+}
+
+// goldenStep compares the project in dir with the golden tree, or replaces
+// the golden tree with it when update is set.
+func goldenStep(dir, golden string, update, rendered bool) templatetest.Step {
+	step := templatetest.Step{Name: templatetest.StepGolden, Status: templatetest.Pass}
+	if !rendered {
+		step.Status = templatetest.Skip
+		return step
+	}
+	start := time.Now()
+	defer func() { step.DurationMS = time.Since(start).Milliseconds() }()
+	if update {
+		if err := templatetest.Update(dir, golden); err != nil {
+			step.Status, step.Error = templatetest.Fail, err.Error()
+		}
+		return step
+	}
+	if !writer.DirectoryExists(golden) {
+		step.Status, step.Error = templatetest.Fail, fmt.Sprintf("no golden tree at %s; run with --update to create it", golden)
+		return step
+	}
+	diffs, err := templatetest.Compare(dir, golden)
+	switch {
+	case err != nil:
+		step.Status, step.Error = templatetest.Fail, err.Error()
+	case len(diffs) > 0:
+		step.Status, step.Error, step.Output = templatetest.Fail, fmt.Sprintf("the snapshot %s differs in %s", golden, plural(len(diffs), "file")), diffs
+	}
+	return step
+}
+
+// printTestReport prints the pass/fail matrix of the cases, then what
+// failed.
+func printTestReport(r testReport) error {
+	header := []string{"CASE"}
+	for _, s := range r.Cases[0].Steps {
+		header = append(header, strings.ToUpper(s.Name))
+	}
+	data := make([][]string, 0, len(r.Cases))
+	for _, c := range r.Cases {
+		row := []string{c.Name}
+		for _, s := range c.Steps {
+			row = append(row, string(s.Status))
+		}
+		data = append(data, row)
+	}
+	fmt.Println()
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header(header)
+	table.Bulk(data)
+	if err := table.Render(); err != nil {
+		return fmt.Errorf("failed to render table: %w", err)
+	}
+	fmt.Println()
+
+	for _, c := range r.Cases {
+		for _, s := range c.Steps {
+			if s.Status != templatetest.Fail {
+				continue
+			}
+			fmt.Printf("%s %s %s: %s\n", red+"✗"+reset, accent(c.Name), s.Name, s.Error)
+			for _, line := range s.Output {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+		if c.Dir != "" {
+			fmt.Printf("  %s %s\n", dimmed(c.Name+" kept in"), c.Dir)
+		}
+	}
+	if r.OK {
+		fmt.Printf("%s %s passed\n", green+"✓"+reset, plural(len(r.Cases), "case"))
+	}
+	return nil
+}
+
+type newReport struct {
+	Template string   `json:"template" yaml:"template"`
+	Dir      string   `json:"dir" yaml:"dir"`
+	Files    []string `json:"files" yaml:"files"`
+}
+
+type fromProjectReport struct {
+	Template string `json:"template" yaml:"template"`
+	Dir      string `json:"dir" yaml:"dir"`
+	*scaffold.Conversion
+}
+
+func runTemplateNew(in *invocation) error {
+	name := in.args[0]
+	if err := rules.ValidateTemplateName(name); err != nil {
+		return newError(CodeInvalidInput, err)
+	}
+	if _, err := os.Stat(name); err == nil {
+		return newError(CodeAlreadyExists, fmt.Errorf("%s already exists", name))
+	}
+	files, err := scaffold.New(name, name)
+	if err != nil {
+		return fmt.Errorf("failed to create template: %w", err)
+	}
+
+	if structuredOutput() {
+		return writeStructured(os.Stdout, newReport{Template: name, Dir: name, Files: files})
+	}
+	fmt.Printf("%s Created template %s\n\n", green+"✓"+reset, accent(name))
+	for _, f := range files {
+		fmt.Printf("  %s\n", filepath.Join(name, f))
+	}
+	fmt.Printf("\n%s\n", section("Next steps:"))
+	fmt.Printf("  %s\n", cmd("cosmos template lint "+name))
+	fmt.Printf("  %s\n\n", cmd(fmt.Sprintf("cosmos template test %s --answers '%s/%s/*.yaml'", name, name, scaffold.CasesDir)))
+	return nil
+}
+
+func runTemplateFromProject(in *invocation) error {
+	src := in.args[0]
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	dest := filepath.Base(abs) + "-template"
+	if len(in.args) > 1 {
+		dest = in.args[1]
+	}
+	name := filepath.Base(dest)
+	if err := rules.ValidateTemplateName(name); err != nil {
+		return newError(CodeInvalidInput, err)
+	}
+	if !writer.FileExists(filepath.Join(src, "go.mod")) {
+		return newError(CodeNotFound, fmt.Errorf("%s is not a Go module: no go.mod", src))
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return newError(CodeAlreadyExists, fmt.Errorf("%s already exists", dest))
+	}
+	c, err := scaffold.FromProject(src, dest, name)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s: %w", src, err)
+	}
+
+	if structuredOutput() {
+		return writeStructured(os.Stdout, fromProjectReport{Template: name, Dir: dest, Conversion: c})
+	}
+	fmt.Printf("%s Converted %s into template %s\n\n", green+"✓"+reset, accent(src), accent(dest))
+	fmt.Printf("  %s %s → {{.Module}}\n", dimmed("module      "), c.Module)
+	fmt.Printf("  %s %s → {{.ProjectName}}\n", dimmed("project name"), c.ProjectName)
+	fmt.Printf("  %s %s, %s copied", dimmed("files       "), plural(len(c.Templates), "template"), plural(len(c.Copied), "file"))
+	if len(c.Verbatim) > 0 {
+		fmt.Printf(", %d verbatim", len(c.Verbatim))
+	}
+	fmt.Printf("\n\n%s\n", section("Next steps:"))
+	fmt.Printf("  %s\n\n", cmd("cosmos template lint "+dest))
+	return nil
+}
+
+// plural is "1 error", "2 errors".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func printTemplateUsage(w io.Writer) {
+	printBanner(w)
+	fmt.Fprintf(w, `%s
+
+  %s template new %s
+      Create the template directory <name> to start from: a template.yaml with
+      prompts, features and files, an example .tmpl file, a partial and a test
+      case in cases/.
+  %s template from-project %s %s
+      Convert the Go project in <dir> into a template in <dest> (default:
+      <dir>-template). Its module path becomes {{.Module}}, its name (the
+      last element of the module path) {{.ProjectName}} in cmd/<name> and in
+      files other than Go sources, go.mod and go.sum.
+  %s template lint %s    Check a template directory before publishing it
+  %s template test %s %s
+      Generate a project from the template for each answers file (a case) in
+      a temporary directory, then run go build, go vet and go test in it, and
+      optionally compare it with a golden snapshot. Prints a pass/fail matrix.
+      Hooks of external templates it extends need --trust-hooks (or --no-hooks).
+
+  lint reports errors, which make the exit status non-zero, and warnings:
+    schema        template.yaml does not match the template.yaml JSON Schema
+    parse         a .tmpl file, partial, path element or hook does not parse
+    partials      {{template "x"}} of a partial that does not exist
+    values        .Values.x with no prompt or default x
+    prompts       a prompt no file, path or hook uses (warning)
+    features      a feature no file is named after or template mentions (warning)
+    files         a files.* pattern matching no file, or a file no include matches (warning)
+    types         a type other than api, worker or cli
+    placeholder   the module placeholder is undeclared, not in go.mod, or not replaced (warning)
+
+  test cases are YAML answers files, as for init --answers. They may also set
+  module and projectName (default: example.com/<case> and the case name, which
+  is the file name without extension). Without answers, one case named
+  default runs with the prompts' defaults.
+
+%s
+  %s        Answers file or glob pattern (test); more files may follow <dir>
+  %s            Compare each case with the snapshot in <dir>/<case> (test)
+  %s                   Write the snapshots instead of comparing (test)
+  %s                     Keep the generated projects and print where (test)
+  %s                 Do not run the template's hooks (test)
+  %s              Run the hooks of external templates it extends (test)
+
+%s
+  %s cosmos template new my-template
+  %s cosmos template from-project ./orders orders-template
+  %s cosmos template lint ./templates/api-hexagonal
+  %s cosmos template lint . --output json
+  %s cosmos template test . --answers 'cases/*.yaml' --golden testdata/golden
+
+`,
+		title("Template authoring."),
+		cmd("cosmos"), accent("<name>"),
+		cmd("cosmos"), accent("<dir>"), accent("[<dest>]"),
+		cmd("cosmos"), accent("<dir>"),
+		cmd("cosmos"), accent("<dir>"), flagStyle("[--answers <files>] [--golden <dir> [--update]] [--keep] [--no-hooks | --trust-hooks]"),
+		section("FLAGS:"),
+		flagStyle("--answers <files>"),
+		flagStyle("--golden <dir>"),
+		flagStyle("--update"),
+		flagStyle("--keep"),
+		flagStyle("--no-hooks"),
+		flagStyle("--trust-hooks"),
+		section("EXAMPLES:"),
+		dimmed("$"),
+		dimmed("$"),
+		dimmed("$"),
+		dimmed("$"),
+		dimmed("$"),
+	)
+}
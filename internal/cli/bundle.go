@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cosmos-toolkit/cli/internal/bundle"
+	"github.com/cosmos-toolkit/cli/internal/resolver"
+)
+
+const defaultBundleFile = "cosmos-bundle.tar.gz"
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func runBundleExport(in *invocation) error {
+	opts := bundle.ExportOpts{
+		Templates: splitList(in.String("templates")),
+		Packages:  splitList(in.String("packages")),
+	}
+	if len(opts.Templates) == 0 && len(opts.Packages) == 0 {
+		return newError(CodeUsage, fmt.Errorf("nothing to export: use --templates and/or --packages"))
+	}
+	file := in.String("file")
+	if file == "" {
+		file = defaultBundleFile
+	}
+
+	// Write to a temporary file so that a failed export leaves nothing behind.
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".cosmos-bundle-*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", file, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	m, err := bundle.Export(tmp, opts)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return newError(resolveErrorCode(err), fmt.Errorf("failed to export bundle: %w", err))
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+
+	if structuredOutput() {
+		return writeStructured(os.Stdout, map[string]any{"file": file, "manifest": m})
+	}
+	for _, e := range m.Templates {
+		fmt.Printf("  %s template %s %s\n", dimmed("+"), accent(e.Name), dimmed(e.Commit))
+	}
+	for _, e := range m.Packages {
+		fmt.Printf("  %s package  %s %s\n", dimmed("+"), accent(e.Name), dimmed(e.Commit))
+	}
+	fmt.Printf("%s Bundle written to %s\n", green+"✓"+reset, accent(file))
+	return nil
+}
+
+func runBundleImport(in *invocation) error {
+	f, err := os.Open(in.args[0])
+	if err != nil {
+		return newError(CodeNotFound, fmt.Errorf("failed to open bundle: %w", err))
+	}
+	defer f.Close()
+
+	res, err := bundle.Import(f, in.Bool("force"))
+	if err != nil {
+		code := CodeInvalidInput
+		if errors.Is(err, resolver.ErrLocked) {
+			code = CodeLocked
+		}
+		return newError(code, fmt.Errorf("failed to import %s: %w", in.args[0], err))
+	}
+	if structuredOutput() {
+		return writeStructured(os.Stdout, res)
+	}
+	root, _ := resolver.CacheDir()
+	for _, s := range res.Imported {
+		fmt.Printf("  %s %s\n", green+"+"+reset, s)
+	}
+	for _, s := range res.Skipped {
+		fmt.Printf("  %s %s %s\n", dimmed("="), s, dimmed("(already cached; use --force to replace)"))
+	}
+	fmt.Printf("%s Bundle imported into %s\n", green+"✓"+reset, dimmed(root))
+	return nil
+}
+
+func printBundleUsage(w io.Writer) {
+	printBanner(w)
+	fmt.Fprintf(w, `%s
+
+  %s bundle export %s %s %s
+      Package templates and packages (plus their copy_deps) from the cache,
+      with manifests and commit metadata. Missing entries are fetched first.
+  %s bundle import %s %s
+      Seed the cache from a bundle so that init and pkg work offline.
+      Entries already cached are kept unless %s is used.
+
+%s
+  %s     Comma-separated template names (export)
+  %s      Comma-separated package names (export)
+  %s    Output file (export, default %s)
+  %s                       Replace entries already in the cache (import)
+
+%s
+  %s cosmos bundle export --templates api-hexagonal --packages logger,config -o bundle.tar.gz
+  %s cosmos bundle import bundle.tar.gz
+  %s cosmos --offline init myapp --template api-hexagonal --module github.com/me/myapp
+
+`,
+		title("Export and import offline bundles."),
+		cmd("cosmos"), flagStyle("--templates a,b"), flagStyle("--packages x,y"), flagStyle("-o <file>"),
+		cmd("cosmos"), accent("<file>"), flagStyle("[--force]"),
+		flagStyle("--force"),
+		section("FLAGS:"),
+		flagStyle("--templates <names>"),
+		flagStyle("--packages <names>"),
+		flagStyle("--file, -o <file>"), defaultBundleFile,
+		flagStyle("--force"),
+		section("EXAMPLES:"),
+		dimmed("$"), dimmed("$"), dimmed("$"),
+	)
+}
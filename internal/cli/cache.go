@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cosmos-toolkit/cli/internal/resolver"
+	"github.com/olekukonko/tablewriter"
+)
+
+// defaultPruneDays is how long an unused cache entry is kept by 'cache prune'.
+const defaultPruneDays = 30
+
+var cacheKinds = []string{"templates", "packages"}
+
+// cacheKind maps the CLI argument (templates, packages) to a resolver kind.
+func cacheKind(arg string) (string, error) {
+	switch arg {
+	case "templates":
+		return resolver.KindTemplate, nil
+	case "packages", "pkgs":
+		return resolver.KindPackage, nil
+	}
+	return "", newError(CodeUsage, fmt.Errorf("unknown cache %q. Valid caches: templates, packages", arg))
+}
+
+func completeCacheKinds(pos int) []string {
+	if pos == 0 {
+		return cacheKinds
+	}
+	return nil
+}
+
+func runCacheList(*invocation) error {
+	entries, err := resolver.ListCache()
+	if err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = []resolver.CacheEntry{}
+	}
+	if structuredOutput() {
+		return writeStructured(os.Stdout, map[string][]resolver.CacheEntry{"entries": entries})
+	}
+
+	root, _ := resolver.CacheDir()
+	printBanner(os.Stdout)
+	fmt.Printf("%s\n\n", title("Cached templates and packages"))
+	fmt.Printf("%s\n\n", dimmed(root))
+	if len(entries) == 0 {
+		fmt.Printf("  %s\n\n", dimmed("(cache is empty)"))
+		return nil
+	}
+
+	data := make([][]string, 0, len(entries))
+	for _, e := range entries {
+		commit := e.Commit
+		if commit == "" {
+			commit = "-"
+		}
+		data = append(data, []string{e.Kind, e.Name, commit, formatSize(e.Size), formatTime(e.LastUpdated), formatTime(e.LastUsed)})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header([]string{"KIND", "NAME", "COMMIT", "SIZE", "UPDATED", "LAST USED"})
+	table.Bulk(data)
+	if err := table.Render(); err != nil {
+		return fmt.Errorf("failed to render table: %w", err)
+	}
+	fmt.Println()
+	return nil
+}
+
+func runCacheClean(in *invocation) error {
+	kind := ""
+	if len(in.args) == 1 {
+		k, err := cacheKind(in.args[0])
+		if err != nil {
+			return err
+		}
+		kind = k
+	}
+	removed, err := resolver.CleanCache(kind)
+	if err != nil {
+		return err
+	}
+	if removed == nil {
+		removed = []string{}
+	}
+	if structuredOutput() {
+		return writeStructured(os.Stdout, map[string][]string{"removed": removed})
+	}
+	if len(removed) == 0 {
+		fmt.Println(dimmed("Nothing to clean."))
+		return nil
+	}
+	for _, dir := range removed {
+		fmt.Printf("%s Removed %s\n", green+"✓"+reset, dimmed(dir))
+	}
+	return nil
+}
+
+func runCachePrune(in *invocation) error {
+	days := defaultPruneDays
+	if in.Changed("days") {
+		d, err := strconv.Atoi(in.String("days"))
+		if err != nil || d < 0 {
+			return newError(CodeUsage, fmt.Errorf("invalid --days %q: expected a non-negative number", in.String("days")))
+		}
+		days = d
+	}
+	dryRun := in.Bool("dry-run")
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	pruned, err := resolver.PruneCache(cutoff, dryRun)
+	if err != nil {
+		return err
+	}
+	if pruned == nil {
+		pruned = []resolver.CacheEntry{}
+	}
+	if structuredOutput() {
+		return writeStructured(os.Stdout, map[string]any{"dryRun": dryRun, "pruned": pruned})
+	}
+	if len(pruned) == 0 {
+		fmt.Println(dimmed(fmt.Sprintf("No cache entries unused for %d days.", days)))
+		return nil
+	}
+	verb := "Pruned"
+	if dryRun {
+		verb = "Would prune"
+	}
+	for _, e := range pruned {
+		fmt.Printf("%s %s %s %s %s\n", green+"✓"+reset, verb, e.Kind, accent(e.Name), dimmed("(last used "+formatTime(lastActivity(e))+")"))
+	}
+	return nil
+}
+
+func lastActivity(e resolver.CacheEntry) time.Time {
+	if e.LastUsed.IsZero() {
+		return e.LastUpdated
+	}
+	return e.LastUsed
+}
+
+func runCachePath(in *invocation) error {
+	root, err := resolver.CacheDir()
+	if err != nil {
+		return err
+	}
+	path := root
+	if len(in.args) == 1 {
+		kind, err := cacheKind(in.args[0])
+		if err != nil {
+			return err
+		}
+		repo := resolver.TemplatesRepoPath
+		if kind == resolver.KindPackage {
+			repo = resolver.PackagesRepoPath
+		}
+		if path, err = repo(); err != nil {
+			return err
+		}
+		path = filepath.Dir(path)
+	}
+	if structuredOutput() {
+		return writeStructured(os.Stdout, map[string]string{"path": path})
+	}
+	fmt.Println(path)
+	return nil
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -8,6 +8,7 @@ var (
 	bold    = "\033[1m"
 	dim     = "\033[2m"
 	cyan    = "\033[36m"
+	red     = "\033[31m"
 	green   = "\033[32m"
 	yellow  = "\033[33m"
 	magenta = "\033[35m"
@@ -17,21 +18,27 @@ var (
 
 func init() {
 	if os.Getenv("NO_COLOR") != "" {
-		reset = ""
-		bold = ""
-		dim = ""
-		cyan = ""
-		green = ""
-		yellow = ""
-		magenta = ""
-		blue = ""
-		white = ""
+		disableColors()
 	}
 }
 
-func title(s string) string    { return bold + cyan + s + reset }
-func section(s string) string  { return bold + yellow + s + reset }
-func cmd(s string) string      { return green + s + reset }
+// disableColors turns every style into a no-op (NO_COLOR, --output json|yaml).
+func disableColors() {
+	reset = ""
+	bold = ""
+	dim = ""
+	cyan = ""
+	red = ""
+	green = ""
+	yellow = ""
+	magenta = ""
+	blue = ""
+	white = ""
+}
+
+func title(s string) string     { return bold + cyan + s + reset }
+func section(s string) string   { return bold + yellow + s + reset }
+func cmd(s string) string       { return green + s + reset }
 func flagStyle(s string) string { return magenta + s + reset }
-func dimmed(s string) string   { return dim + s + reset }
-func accent(s string) string   { return cyan + s + reset }
+func dimmed(s string) string    { return dim + s + reset }
+func accent(s string) string    { return cyan + s + reset }
@@ -1,19 +1,21 @@
 package cli
 
 import (
-	"flag"
+	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/cosmos-toolkit/cli/internal/catalog"
-	"github.com/cosmos-toolkit/cli/internal/github"
-	"github.com/cosmos-toolkit/cli/internal/loader"
+	"github.com/cosmos-toolkit/cli/internal/config"
+	"github.com/cosmos-toolkit/cli/internal/hooks"
 	"github.com/cosmos-toolkit/cli/internal/pkginstall"
+	"github.com/cosmos-toolkit/cli/internal/provider"
+	"github.com/cosmos-toolkit/cli/internal/registry"
 	"github.com/cosmos-toolkit/cli/internal/renderer"
 	"github.com/cosmos-toolkit/cli/internal/resolver"
 	"github.com/cosmos-toolkit/cli/internal/rules"
@@ -44,98 +46,325 @@ type Config struct {
 	Module      string
 	Template    string
 	Force       bool
+	NoHooks     bool
+	TrustHooks  bool
+	AllowEnv    bool
+	// Answers is a YAML file of answers to the template's prompts.
+	Answers string
+	// NoPrompt makes a required prompt without a value an error instead of
+	// a question.
+	NoPrompt bool
 }
 
 func Execute() error {
+	root := newRootCommand()
 	args := os.Args[1:]
+	if len(args) > 0 && args[0] == completeCommand {
+		return runComplete(root, args[1:])
+	}
 
-	// No args or --help/-h at top level
-	if len(args) == 0 {
-		printUsage(os.Stdout)
-		return nil
+	in, parseErr := root.parse(args)
+	cfg, cfgErr := config.Load()
+	if cfgErr != nil {
+		cfg = &config.Config{}
 	}
-	if args[0] == "--help" || args[0] == "-h" {
-		printUsage(os.Stdout)
-		return nil
+	if v, ok := in.values["output"]; ok || cfg.Output != "" {
+		if !ok {
+			v = cfg.Output
+		}
+		f, err := parseOutputFormat(v)
+		if err != nil {
+			return err
+		}
+		setOutputFormat(f)
 	}
-	if args[0] == "version" || args[0] == "--version" || args[0] == "-v" {
-		fmt.Printf("%s %s %s\n", cmd("cosmos"), dimmed("version"), accent(version))
-		return nil
+	resolver.SetOffline(in.Bool("offline") || envOffline() || cfg.Offline)
+	if ttl, ok := cfg.TTL(); ok {
+		resolver.SetCacheTTL(ttl)
+	}
+	if cfg.Fetcher != "" {
+		resolver.SetFetcher(cfg.Fetcher)
+	}
+	if dir, err := resolver.HTTPCacheDir(); err == nil {
+		provider.SetCacheDir(dir)
+	}
+	if err := configureRegistries(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%s ignoring registries: %v\n", yellow+"warning:"+reset, err)
+	}
+	if cfgErr != nil && in.cmd.name != "doctor" {
+		fmt.Fprintf(os.Stderr, "%s ignoring config: %v (run 'cosmos doctor')\n", yellow+"warning:"+reset, cfgErr)
 	}
+	resolver.SetOutput(subprocessOutput())
+	if parseErr != nil {
+		return parseErr
+	}
+	switch {
+	case in.Bool("refresh") && in.Bool("no-refresh"):
+		return newError(CodeUsage, fmt.Errorf("--refresh and --no-refresh cannot be used together"))
+	case in.Bool("refresh"):
+		resolver.SetRefresh(resolver.RefreshAlways)
+	case in.Bool("no-refresh"):
+		resolver.SetRefresh(resolver.RefreshNever)
+	}
+	return in.execute(os.Stdout)
+}
 
-	switch args[0] {
-	case "list":
-		return executeList(args[1:])
-	case "init":
-		return executeInitCommand(args[1:])
-	case "pkg":
-		return executePkg(args[1:])
-	case "update":
-		return executeUpdate(args[1:])
-	case "cache":
-		return executeCache(args[1:])
-	default:
-		return fmt.Errorf("unknown command: %s\n\nRun 'cosmos --help' for usage", args[0])
+// refreshFlag and noRefreshFlag override the cache TTL for commands that
+// resolve templates or packages.
+var (
+	refreshFlag   = &flagDef{name: "refresh", help: "Pull the template/package cache before use"}
+	noRefreshFlag = &flagDef{name: "no-refresh", help: "Never pull the cache, even when it is stale"}
+)
+
+// newRootCommand builds the command tree. Every command accepts --help/-h
+// and the persistent --output flag.
+func newRootCommand() *command {
+	root := &command{
+		name:  "cosmos",
+		usage: printUsage,
+		flags: []*flagDef{
+			{name: "output", value: "format", persistent: true, help: "Output format: table, json or yaml",
+				complete: func() []string { return []string{string(outputTable), string(outputJSON), string(outputYAML)} }},
+			{name: "offline", persistent: true, help: "Use only local caches and the embedded catalog (also COSMOS_OFFLINE=1)"},
+			{name: "version", short: "v", help: "Show version"},
+		},
+		run: func(in *invocation) error {
+			if in.Bool("version") {
+				return runVersion(in)
+			}
+			printUsage(os.Stdout)
+			return nil
+		},
 	}
+	return root.add(
+		&command{name: "version", short: "Show version", run: runVersion},
+		&command{
+			name:  "init",
+			short: "Initialize a new Go project from a template",
+			usage: printInitUsage,
+			flags: []*flagDef{
+				{name: "module", value: "module-path", help: "Go module path (required)"},
+				{name: "template", value: "name", help: "External template name", complete: resolver.CachedTemplates},
+				{name: "force", help: "Overwrite existing project directory"},
+				{name: "list", short: "l", help: "List available built-in and external templates"},
+				{name: "interactive", short: "i", help: "Interactive setup"},
+				{name: "no-hooks", help: "Do not run the template's pre_render/post_render hooks"},
+				{name: "trust-hooks", help: "Run hooks of external templates without asking"},
+				{name: "allow-env", help: "Let templates read environment variables (env function)"},
+				{name: "answers", value: "file", help: "YAML file of answers to the template's prompts"},
+				refreshFlag, noRefreshFlag,
+			},
+			maxArgs:  2,
+			complete: completeInitArgs,
+			run:      runInit,
+		},
+		&command{
+			name:  "pkg",
+			short: "Install a reusable package into the current project",
+			usage: printPkgUsage,
+			flags: []*flagDef{
+				{name: "force", short: "f", help: "Overwrite existing pkg/<name>"},
+				{name: "interactive", short: "i", help: "Select packages interactively"},
+				refreshFlag, noRefreshFlag,
+			},
+			maxArgs:  1,
+			complete: completePackages,
+			run:      runPkg,
+		},
+		(&command{name: "list", short: "List templates and packages", usage: printListUsage}).add(
+			&command{name: "templates", short: "List available templates", run: func(*invocation) error { return runListTemplates(os.Stdout) }},
+			&command{name: "pkgs", aliases: []string{"packages"}, short: "List available packages", run: func(*invocation) error { return runListPackages(os.Stdout) }},
+		),
+		&command{name: "doctor", short: "Diagnose the environment", usage: printDoctorUsage, run: runDoctor},
+		&command{name: "update", short: "Refresh templates and packages cache", usage: printUpdateUsage, run: runUpdate},
+		(&command{name: "cache", short: "Cache operations", usage: printCacheUsage}).add(
+			&command{name: "refresh", short: "Refresh templates and packages cache", usage: printUpdateUsage, run: runUpdate},
+			&command{name: "list", short: "List cached templates and packages", usage: printCacheUsage, run: runCacheList},
+			&command{name: "clean", short: "Remove the templates and/or packages cache", usage: printCacheUsage,
+				maxArgs: 1, complete: completeCacheKinds, run: runCacheClean},
+			&command{
+				name:  "prune",
+				short: "Drop cache entries not used recently",
+				usage: printCacheUsage,
+				flags: []*flagDef{
+					{name: "days", value: "n", help: fmt.Sprintf("Prune entries unused for n days (default %d)", defaultPruneDays)},
+					{name: "dry-run", help: "Only show what would be pruned"},
+				},
+				run: runCachePrune,
+			},
+			&command{name: "path", short: "Print the cache directory", usage: printCacheUsage,
+				maxArgs: 1, complete: completeCacheKinds, run: runCachePath},
+		),
+		(&command{name: "bundle", short: "Export and import offline bundles", usage: printBundleUsage}).add(
+			&command{
+				name:  "export",
+				short: "Write selected templates and packages to a bundle",
+				usage: printBundleUsage,
+				flags: []*flagDef{
+					{name: "templates", value: "names", help: "Comma-separated template names", complete: resolver.CachedTemplates},
+					{name: "packages", value: "names", help: "Comma-separated package names", complete: resolver.CachedPackages},
+					{name: "file", short: "o", value: "file", help: "Output file (default " + defaultBundleFile + ")"},
+					refreshFlag, noRefreshFlag,
+				},
+				run: runBundleExport,
+			},
+			&command{
+				name:    "import",
+				short:   "Seed the cache from a bundle",
+				usage:   printBundleUsage,
+				flags:   []*flagDef{{name: "force", help: "Replace entries already in the cache"}},
+				minArgs: 1,
+				maxArgs: 1,
+				run:     runBundleImport,
+			},
+		),
+		(&command{name: "template", short: "Create, check and test templates you author", usage: printTemplateUsage}).add(
+			&command{
+				name:    "new",
+				short:   "Create a template directory to start from",
+				usage:   printTemplateUsage,
+				minArgs: 1,
+				maxArgs: 1,
+				run:     runTemplateNew,
+			},
+			&command{
+				name:    "from-project",
+				short:   "Convert a Go project into a template",
+				usage:   printTemplateUsage,
+				minArgs: 1,
+				maxArgs: 2,
+				run:     runTemplateFromProject,
+			},
+			&command{
+				name:    "lint",
+				short:   "Check a template directory",
+				usage:   printTemplateUsage,
+				minArgs: 1,
+				maxArgs: 1,
+				run:     runTemplateLint,
+			},
+			&command{
+				name:  "test",
+				short: "Generate projects from a template and check that they build",
+				usage: printTemplateUsage,
+				flags: []*flagDef{
+					{name: "answers", value: "files", help: "Answers file or glob pattern, one case per file"},
+					{name: "golden", value: "dir", help: "Compare each case with the snapshot in <dir>/<case>"},
+					{name: "update", help: "Write the snapshots instead of comparing (with --golden)"},
+					{name: "keep", help: "Keep the generated projects"},
+					{name: "no-hooks", help: "Do not run the template's hooks"},
+					{name: "trust-hooks", help: "Run the hooks of external templates it extends"},
+				},
+				minArgs: 1,
+				maxArgs: -1,
+				run:     runTemplateTest,
+			},
+		),
+		&command{
+			name:     "completion",
+			short:    "Generate shell completion scripts",
+			usage:    printCompletionUsage,
+			minArgs:  1,
+			maxArgs:  1,
+			complete: completeShells,
+			run:      runCompletion,
+		},
+	)
 }
 
-func executeInitCommand(args []string) error {
-	// cosmos init --help or cosmos init -h
-	if len(args) >= 1 && (args[0] == "--help" || args[0] == "-h") {
-		printInitUsage(os.Stdout)
-		return nil
+func runVersion(*invocation) error {
+	if structuredOutput() {
+		return writeStructured(os.Stdout, map[string]string{"version": version})
 	}
+	fmt.Printf("%s %s %s\n", cmd("cosmos"), dimmed("version"), accent(version))
+	return nil
+}
 
+// configureRegistries applies the registries and ref of the config file.
+func configureRegistries(cfg *config.Config) error {
+	build := func(kind string, r *config.Registry) provider.Registry {
+		reg := registry.Defaults(kind)
+		if r != nil {
+			reg = provider.Registry{Type: r.Type, URL: r.URL, API: r.API, Repo: r.Repo, Ref: r.Ref, Token: r.Token, Protocol: r.Protocol}
+		}
+		if reg.Ref == "" {
+			reg.Ref = cfg.Ref
+		}
+		return reg
+	}
+	checkout := func(kind string) (string, error) {
+		if kind == registry.Packages {
+			return resolver.Checkout(resolver.KindPackage)
+		}
+		return resolver.Checkout(resolver.KindTemplate)
+	}
+	return registry.Configure(build(registry.Templates, cfg.Registries.Templates), build(registry.Packages, cfg.Registries.Packages), checkout)
+}
+
+// envOffline reports whether COSMOS_OFFLINE is set to a true value.
+func envOffline() bool {
+	v, err := strconv.ParseBool(os.Getenv("COSMOS_OFFLINE"))
+	return err == nil && v
+}
+
+// listExternalTemplates lists templates from the GitHub API, or from the
+// local cache in offline mode.
+func listExternalTemplates() ([]registry.TemplateInfo, error) {
+	if resolver.IsOffline() {
+		return resolver.CachedTemplatesWithInfo(), nil
+	}
+	return registry.ListTemplatesWithInfo()
+}
+
+// listPackages lists packages from the GitHub API, or from the local cache
+// in offline mode.
+func listPackages() ([]registry.PackageInfo, error) {
+	if resolver.IsOffline() {
+		return resolver.CachedPackagesWithInfo(), nil
+	}
+	return registry.ListPackagesWithInfo()
+}
+
+// sourceNote is the origin shown under listing titles.
+func sourceNote(repo string) string {
+	if resolver.IsOffline() {
+		return repo + " (offline: local cache only)"
+	}
+	return repo
+}
+
+func runInit(in *invocation) error {
 	// cosmos init --list or cosmos init -l
-	if len(args) >= 1 && (args[0] == "--list" || args[0] == "-l") {
-		printTemplateList(os.Stdout)
-		return nil
+	if in.Bool("list") {
+		return printTemplateList(os.Stdout)
 	}
 
-	// cosmos init (no args) or cosmos init --interactive/-i -> interactive mode
-	initArgs := args
-	if len(initArgs) == 0 || (len(initArgs) == 1 && (initArgs[0] == "--interactive" || initArgs[0] == "-i")) {
+	// cosmos init (no args) or cosmos init --interactive/-i -> interactive
+	// mode; global and cache flags (--offline, --refresh) still apply to it
+	if in.Bool("interactive") || (len(in.args) == 0 && !in.ownFlagsChanged(refreshFlag, noRefreshFlag)) {
 		return runInteractiveInit()
 	}
 
-	config, err := parseInitCommand(initArgs)
+	config, err := parseInitCommand(in)
 	if err != nil {
-		if err == flag.ErrHelp {
-			printInitUsage(os.Stdout)
-			return nil
-		}
-		return err
+		return newError(CodeUsage, err)
 	}
 
 	return executeInit(config)
 }
 
-func executeList(args []string) error {
-	// cosmos list or cosmos list --help
-	if len(args) == 0 || args[0] == "--help" || args[0] == "-h" {
-		printListUsage(os.Stdout)
-		return nil
+func runListTemplates(w io.Writer) error {
+	templates, err := listExternalTemplates()
+	if err != nil {
+		return newError(resolveErrorCode(err), fmt.Errorf("failed to list templates: %w", err))
 	}
-
-	switch args[0] {
-	case "templates":
-		return runListTemplates(os.Stdout)
-	case "pkgs", "packages":
-		return runListPackages(os.Stdout)
-	default:
-		return fmt.Errorf("unknown subcommand: %s\n\nRun 'cosmos list --help' for usage", args[0])
+	if structuredOutput() {
+		return writeStructured(w, map[string][]registry.TemplateInfo{"templates": templates})
 	}
-}
 
-func runListTemplates(w io.Writer) error {
 	printBanner(w)
 	fmt.Fprintf(w, "%s\n\n", title("Available templates"))
-	fmt.Fprintf(w, "%s\n\n", dimmed("github.com/cosmos-toolkit/templates"))
-
-	templates, err := github.ListTemplatesWithInfo()
-	if err != nil {
-		return fmt.Errorf("failed to list templates: %w", err)
-	}
+	fmt.Fprintf(w, "%s\n\n", dimmed(sourceNote("github.com/cosmos-toolkit/templates")))
 	if len(templates) == 0 {
 		fmt.Fprintf(w, "  %s\n", dimmed("(no templates yet)"))
 		fmt.Fprintln(w)
@@ -158,14 +387,17 @@ func runListTemplates(w io.Writer) error {
 }
 
 func runListPackages(w io.Writer) error {
-	printBanner(w)
-	fmt.Fprintf(w, "%s\n\n", title("Available packages"))
-	fmt.Fprintf(w, "%s\n\n", dimmed("github.com/cosmos-toolkit/packages"))
-
-	pkgs, err := github.ListPackagesWithInfo()
+	pkgs, err := listPackages()
 	if err != nil {
-		return fmt.Errorf("failed to list packages: %w", err)
+		return newError(resolveErrorCode(err), fmt.Errorf("failed to list packages: %w", err))
 	}
+	if structuredOutput() {
+		return writeStructured(w, map[string][]registry.PackageInfo{"packages": pkgs})
+	}
+
+	printBanner(w)
+	fmt.Fprintf(w, "%s\n\n", title("Available packages"))
+	fmt.Fprintf(w, "%s\n\n", dimmed(sourceNote("github.com/cosmos-toolkit/packages")))
 	if len(pkgs) == 0 {
 		fmt.Fprintf(w, "  %s\n", dimmed("(no packages yet)"))
 		fmt.Fprintln(w)
@@ -187,56 +419,45 @@ func runListPackages(w io.Writer) error {
 	return nil
 }
 
-func executeUpdate(args []string) error {
-	if len(args) >= 1 && (args[0] == "--help" || args[0] == "-h") {
-		printUpdateUsage(os.Stdout)
-		return nil
-	}
+func runUpdate(*invocation) error {
 	okT, err := resolver.PullTemplatesRepo()
 	if err != nil {
-		return err
-	}
-	if okT {
-		fmt.Printf("%s Templates cache updated\n", green+"✓"+reset)
+		return newError(resolveErrorCode(err), err)
 	}
 	okP, err := resolver.PullPackagesRepo()
 	if err != nil {
-		return err
+		return newError(resolveErrorCode(err), err)
+	}
+	if structuredOutput() {
+		return writeStructured(os.Stdout, updateResult{Templates: okT, Packages: okP})
+	}
+	if okT {
+		fmt.Printf("%s Templates cache updated\n", green+"✓"+reset)
 	}
 	if okP {
 		fmt.Printf("%s Packages cache updated\n", green+"✓"+reset)
 	}
 	if !okT && !okP {
-		fmt.Println(dimmed("No cache found. Use 'cosmos init' or 'cosmos pkg' to create it."))
+		root, _ := resolver.CacheDir()
+		if _, err := os.Stat(root); err == nil {
+			// Caches imported from a bundle are plain directories with no remote.
+			fmt.Println(dimmed("No git cache to update (caches imported from a bundle are not refreshed)."))
+		} else {
+			fmt.Println(dimmed("No cache found. Use 'cosmos init' or 'cosmos pkg' to create it."))
+		}
 	}
 	return nil
 }
 
-func executeCache(args []string) error {
-	if len(args) == 0 || args[0] == "--help" || args[0] == "-h" {
-		printCacheUsage(os.Stdout)
-		return nil
-	}
-	if args[0] != "refresh" {
-		return fmt.Errorf("unknown subcommand: %s\n\nRun 'cosmos cache --help' for usage", args[0])
-	}
-	return executeUpdate(args[1:])
-}
-
-func executePkg(args []string) error {
-	if len(args) >= 1 && (args[0] == "--help" || args[0] == "-h") {
-		printPkgUsage(os.Stdout)
-		return nil
-	}
-
-	force, positionals := parsePkgArgs(args)
+func runPkg(in *invocation) error {
+	force := in.Bool("force")
 
 	// cosmos pkg (no positionals) or only -i/--interactive -> interactive mode
-	if len(positionals) == 0 {
+	if len(in.args) == 0 {
 		return runInteractivePkg(force)
 	}
 
-	name := positionals[0]
+	name := in.args[0]
 	cwd, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("failed to get working directory: %w", err)
@@ -244,32 +465,39 @@ func executePkg(args []string) error {
 
 	pkgDir := filepath.Join(cwd, "pkg", name)
 	if writer.DirectoryExists(pkgDir) && force {
-		fmt.Printf("%s Overwriting existing %s\n", dimmed("→"), dimmed("pkg/"+name))
+		status("%s Overwriting existing %s\n", dimmed("→"), dimmed("pkg/"+name))
 	}
 
-	opts := pkginstall.InstallOpts{Force: force}
+	opts := pkginstall.InstallOpts{Force: force, Output: subprocessOutput()}
 	if err := pkginstall.Install(name, cwd, opts); err != nil {
-		return err
+		return newError(pkgErrorCode(err), err)
 	}
 
+	if structuredOutput() {
+		return writeStructured(os.Stdout, pkgResult{Installed: []installedPackage{{Name: name, Path: pkgDir}}})
+	}
 	fmt.Printf("%s Package %s installed in %s/pkg/%s\n", green+"✓"+reset, accent(name), dimmed(cwd), accent(name))
 	return nil
 }
 
-// parsePkgArgs extracts --force/-f and returns (force, positionals).
-// Positionals are args that are not --help, -h, -i, --interactive, --force, -f.
-func parsePkgArgs(args []string) (force bool, positionals []string) {
-	for _, a := range args {
-		switch a {
-		case "--force", "-f":
-			force = true
-		case "--help", "-h", "--interactive", "-i":
-			// skip
-		default:
-			positionals = append(positionals, a)
-		}
-	}
-	return force, positionals
+// pkgErrorCode maps pkginstall failures to stable error codes.
+func pkgErrorCode(err error) string {
+	switch {
+	case errors.Is(err, resolver.ErrNotCached):
+		return CodeNotCached
+	case errors.Is(err, resolver.ErrLocked):
+		return CodeLocked
+	case errors.Is(err, provider.ErrRateLimited):
+		return CodeRateLimited
+	case errors.Is(err, pkginstall.ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, pkginstall.ErrExists):
+		return CodeAlreadyExists
+	}
+	if code, ok := gitErrorCode(err); ok {
+		return code
+	}
+	return CodeInternal
 }
 
 func printPkgUsage(w io.Writer) {
@@ -290,6 +518,10 @@ func printPkgUsage(w io.Writer) {
 %s
   %s, %s
       Overwrite existing pkg/<name> if it exists (fails by default)
+  %s
+      Pull the packages cache before use (default: only when older than cacheTTL, 24h)
+  %s
+      Never pull the packages cache, even when stale
 
 %s
   %s %s pkg
@@ -308,6 +540,7 @@ func printPkgUsage(w io.Writer) {
 		flagStyle("--force"),
 		section("FLAGS:"),
 		flagStyle("--force"), flagStyle("-f"),
+		flagStyle("--refresh"), flagStyle("--no-refresh"),
 		section("EXAMPLES:"),
 		dimmed("#"), cmd("cosmos"),
 		dimmed("#"), cmd("cosmos"), flagStyle("-i"),
@@ -333,6 +566,9 @@ func printListUsage(w io.Writer) {
 }
 
 func printBanner(w io.Writer) {
+	if structuredOutput() {
+		return
+	}
 	lines := strings.Split(strings.TrimSpace(banner), "\n")
 	for _, line := range lines {
 		fmt.Fprintln(w, line)
@@ -348,25 +584,47 @@ func printUsage(w io.Writer) {
   %s pkg               Install packages (interactive: list, select one or more)
   %s pkg %s       Install a package (logger, config, ...) into current project
   %s update            Refresh templates and packages cache (git pull)
-  %s cache %s    Same as %s update
+  %s doctor            Check git, Go, caches, GitHub API access and config
+  %s cache %s    Refresh, list, clean, prune the cache or print its path
   %s list %s     List available templates
   %s list %s     List available packages
+  %s bundle %s     Export/import offline bundles of templates and packages
+  %s template %s   Create, lint and test templates you author
+  %s completion %s  Generate shell completion (bash, zsh, fish)
 
   %s %s, %s    Show this help
   %s %s, %s    Show version
 
+%s
+  %s %s    Output format: table (default), json or yaml.
+                              json/yaml disable the banner and colors; errors are
+                              printed as {"error": {"code", "message"}}.
+  %s %s           Never use the network: list and resolve only from the local
+                              caches and built-in templates (also COSMOS_OFFLINE=1).
+
+  Flags may appear anywhere after the command and accept %s or %s.
+  Run %s for help on any command.
+
 `,
 		section("USAGE:"),
 		cmd("cosmos"),
 		cmd("cosmos"),
 		cmd("cosmos"), accent("<name>"),
 		cmd("cosmos"),
-		cmd("cosmos"), accent("refresh"),
 		cmd("cosmos"),
+		cmd("cosmos"), accent("<cmd>  "),
 		cmd("cosmos"), accent("templates"),
 		cmd("cosmos"), accent("pkgs"),
+		cmd("cosmos"), accent("<cmd>"),
+		cmd("cosmos"), accent("<cmd>"),
+		cmd("cosmos"), accent("<shell>"),
 		cmd("cosmos"), flagStyle("--help"), flagStyle("-h"),
 		cmd("cosmos"), flagStyle("--version"), flagStyle("-v"),
+		section("GLOBAL FLAGS:"),
+		cmd("cosmos"), flagStyle("--output <format>"),
+		cmd("cosmos"), flagStyle("--offline"),
+		flagStyle("--flag value"), flagStyle("--flag=value"),
+		cmd("cosmos <command> --help"),
 	)
 }
 
@@ -375,7 +633,8 @@ func printUpdateUsage(w io.Writer) {
 	fmt.Fprintf(w, `%s
 
   %s update    Run git pull in the local templates and packages caches
-               (~/.cache/cosmos/templates/_repo and ~/.cache/cosmos/packages/_repo)
+               (<cache>/templates/_repo and <cache>/packages/_repo, where <cache>
+               is $COSMOS_CACHE_DIR, $XDG_CACHE_HOME/cosmos or ~/.cache/cosmos)
                so the next init or pkg uses the latest versions.
 
   If a cache does not exist yet, nothing is done for that cache.
@@ -393,12 +652,31 @@ func printCacheUsage(w io.Writer) {
 	printBanner(w)
 	fmt.Fprintf(w, `%s
 
-  %s cache %s    Same as %s update: refresh templates and packages cache (git pull).
+  %s cache %s                      Same as %s update: refresh templates and packages cache (git pull).
+  %s cache %s                         List cached templates/packages with commit, size, last update and use
+  %s cache %s %s  Remove both caches, or only one of them
+  %s cache %s %s        Drop entries not used for n days (default %d)
+  %s cache %s %s   Print the cache directory
+
+  The cache lives in %s, else %s, else %s.
+
+%s
+  %s
+      prune: number of days an entry may stay unused (default %d)
+  %s
+      prune: show what would be removed without removing anything
 
 `,
 		title("Cache operations."),
-		cmd("cosmos"), accent("refresh"),
-		cmd("cosmos"),
+		cmd("cosmos"), accent("refresh"), cmd("cosmos"),
+		cmd("cosmos"), accent("list"),
+		cmd("cosmos"), accent("clean"), accent("[templates|packages]"),
+		cmd("cosmos"), accent("prune"), flagStyle("[--days n] [--dry-run]"), defaultPruneDays,
+		cmd("cosmos"), accent("path"), accent("[templates|packages]"),
+		accent("$COSMOS_CACHE_DIR"), accent("$XDG_CACHE_HOME/cosmos"), accent("~/.cache/cosmos"),
+		section("FLAGS:"),
+		flagStyle("--days n"), defaultPruneDays,
+		flagStyle("--dry-run"),
 	)
 }
 
@@ -427,11 +705,23 @@ func printInitUsage(w io.Writer) {
       Go module path (required). Example: github.com/user/repo
   %s string
       External template name. Fetched from github.com/cosmos-toolkit/templates/<name>
-      Cached under ~/.cache/cosmos/templates/
+      Cached under <cache>/templates/ (see 'cosmos cache path')
   %s
       Overwrite existing project directory if it exists
   %s, %s
       List available built-in and external templates
+  %s
+      Do not run the template's pre_render/post_render hooks
+  %s
+      Run hooks of external templates without asking (needed when not on a terminal)
+  %s
+      Let templates read environment variables with the env function
+  %s
+      YAML file of answers to the template's prompts (available as .Values)
+  %s
+      Pull the templates cache before use (default: only when older than cacheTTL, 24h)
+  %s
+      Never pull the templates cache, even when stale
 
 %s
   %s List available templates
@@ -462,6 +752,9 @@ func printInitUsage(w io.Writer) {
 		section("FLAGS:"),
 		flagStyle("--module"), flagStyle("--template"), flagStyle("--force"),
 		flagStyle("--list"), flagStyle("-l"),
+		flagStyle("--no-hooks"), flagStyle("--trust-hooks"), flagStyle("--allow-env"),
+		flagStyle("--answers <file>"),
+		flagStyle("--refresh"), flagStyle("--no-refresh"),
 		section("EXAMPLES:"),
 		dimmed("#"), cmd("cosmos"), flagStyle("--list"),
 		dimmed("#"), cmd("cosmos"), flagStyle("--module"),
@@ -478,11 +771,41 @@ var builtInDescriptions = map[string]string{
 	"cli":    "Command-line tool with subcommands",
 }
 
-func printTemplateList(w io.Writer) {
-	printBanner(w)
+// builtInTemplate is a built-in template as printed by --output json|yaml.
+type builtInTemplate struct {
+	catalog.TemplateInfo `yaml:",inline"`
+	Description          string `json:"description" yaml:"description"`
+}
+
+// templateListing is the structured form of 'cosmos init --list'.
+type templateListing struct {
+	BuiltIn       []builtInTemplate       `json:"builtIn" yaml:"builtIn"`
+	External      []registry.TemplateInfo `json:"external" yaml:"external"`
+	ExternalError string                  `json:"externalError,omitempty" yaml:"externalError,omitempty"`
+}
+
+func printTemplateList(w io.Writer) error {
 	cat := catalog.New()
 	builtIn := cat.ListTemplates()
 
+	if structuredOutput() {
+		listing := templateListing{BuiltIn: make([]builtInTemplate, 0, len(builtIn))}
+		for _, t := range builtIn {
+			listing.BuiltIn = append(listing.BuiltIn, builtInTemplate{TemplateInfo: t, Description: builtInDescriptions[t.Type]})
+		}
+		external, err := listExternalTemplates()
+		if err != nil {
+			listing.ExternalError = err.Error()
+		}
+		listing.External = external
+		if listing.External == nil {
+			listing.External = []registry.TemplateInfo{}
+		}
+		return writeStructured(w, listing)
+	}
+
+	printBanner(w)
+
 	fmt.Fprintf(w, "%s\n\n", title("Available templates"))
 	fmt.Fprintf(w, "%s\n", section("Built-in templates (use directly):"))
 	fmt.Fprintf(w, "\n")
@@ -498,11 +821,15 @@ func printTemplateList(w io.Writer) {
 		fmt.Fprintf(w, "      %s init %s <name> %s\n\n", cmd("cosmos"), t.Type, flagStyle("--module <path>"))
 	}
 
-	external, err := github.ListTemplatesWithInfo()
+	external, err := listExternalTemplates()
 	if err != nil {
 		fmt.Fprintf(w, "  %s\n\n", dimmed("(could not list external templates)"))
 	} else {
-		fmt.Fprintf(w, "%s\n", section("External templates (from GitHub):"))
+		if resolver.IsOffline() {
+			fmt.Fprintf(w, "%s\n", section("External templates (offline: from local cache):"))
+		} else {
+			fmt.Fprintf(w, "%s\n", section("External templates (from GitHub):"))
+		}
 		fmt.Fprintf(w, "\n")
 		for _, t := range external {
 			fmt.Fprintf(w, "  %s  %s\n", accent(t.Name), dimmed(t.Description))
@@ -511,9 +838,13 @@ func printTemplateList(w io.Writer) {
 	}
 	fmt.Fprintf(w, "  Use %s to fetch templates from:\n  %s\n\n", flagStyle("--template <name>"), dimmed("github.com/cosmos-toolkit/templates/<name>"))
 	fmt.Fprintf(w, "%s\n", dimmed("Run 'cosmos init --help' for more details."))
+	return nil
 }
 
 func runInteractiveInit() error {
+	if err := requireInteractive(); err != nil {
+		return err
+	}
 	printBanner(os.Stdout)
 	fmt.Println(title("Let's create a new Go project"))
 	fmt.Println()
@@ -535,7 +866,7 @@ func runInteractiveInit() error {
 	}
 
 	// Build template options: built-in + external (from GitHub, descriptions from manifest)
-	externalTemplates, err := github.ListTemplatesWithInfo()
+	externalTemplates, err := listExternalTemplates()
 	if err != nil {
 		externalTemplates = nil // proceed with built-in only
 	}
@@ -618,7 +949,7 @@ func runInteractiveInit() error {
 			return err
 		}
 		if !overwrite {
-			return fmt.Errorf("cancelled: directory %s already exists", projectName)
+			return newError(CodeCancelled, fmt.Errorf("cancelled: directory %s already exists", projectName))
 		}
 		config.Force = true
 	}
@@ -627,16 +958,19 @@ func runInteractiveInit() error {
 }
 
 func runInteractivePkg(force bool) error {
+	if err := requireInteractive(); err != nil {
+		return err
+	}
 	printBanner(os.Stdout)
 	fmt.Println(title("Install packages into the current project"))
 	fmt.Println()
 
-	pkgs, err := github.ListPackagesWithInfo()
+	pkgs, err := listPackages()
 	if err != nil {
-		return fmt.Errorf("failed to list packages: %w", err)
+		return newError(resolveErrorCode(err), fmt.Errorf("failed to list packages: %w", err))
 	}
 	if len(pkgs) == 0 {
-		return fmt.Errorf("no packages available")
+		return newError(CodeNotFound, fmt.Errorf("no packages available"))
 	}
 
 	options := make([]string, len(pkgs))
@@ -692,115 +1026,112 @@ func runInteractivePkg(force bool) error {
 			return err
 		}
 		if !overwrite {
-			return fmt.Errorf("skipped: use --force to overwrite existing packages")
+			return newError(CodeCancelled, fmt.Errorf("skipped: use --force to overwrite existing packages"))
 		}
 		force = true
 	}
 
-	opts := pkginstall.InstallOpts{Force: force}
+	opts := pkginstall.InstallOpts{Force: force, Output: subprocessOutput()}
+	var installed []installedPackage
 	for _, name := range names {
 		pkgDir := filepath.Join(cwd, "pkg", name)
 		if writer.DirectoryExists(pkgDir) && force {
-			fmt.Printf("%s Overwriting existing %s\n", dimmed("→"), dimmed("pkg/"+name))
+			status("%s Overwriting existing %s\n", dimmed("→"), dimmed("pkg/"+name))
 		}
 		if err := pkginstall.Install(name, cwd, opts); err != nil {
-			return fmt.Errorf("failed to install %s: %w", name, err)
+			return newError(pkgErrorCode(err), fmt.Errorf("failed to install %s: %w", name, err))
 		}
-		fmt.Printf("%s Package %s installed in %s/pkg/%s\n", green+"✓"+reset, accent(name), dimmed(cwd), accent(name))
+		installed = append(installed, installedPackage{Name: name, Path: pkgDir})
+		status("%s Package %s installed in %s/pkg/%s\n", green+"✓"+reset, accent(name), dimmed(cwd), accent(name))
 	}
 
+	if structuredOutput() {
+		return writeStructured(os.Stdout, pkgResult{Installed: installed})
+	}
 	return nil
 }
 
 func executeInit(config *Config) error {
 	// Validate inputs
 	if err := rules.ValidateModulePath(config.Module); err != nil {
-		return err
+		return newError(CodeInvalidInput, err)
 	}
 
 	if err := rules.ValidateProjectName(config.ProjectName); err != nil {
-		return err
+		return newError(CodeInvalidInput, err)
 	}
 
 	// Determine output directory
 	outputDir := config.ProjectName
 	if writer.DirectoryExists(outputDir) && !config.Force {
-		return fmt.Errorf("directory %s already exists. Use --force to overwrite", outputDir)
-	}
-
-	if config.Force && writer.DirectoryExists(outputDir) {
-		if err := os.RemoveAll(outputDir); err != nil {
-			return fmt.Errorf("failed to remove existing directory: %w", err)
-		}
+		return newError(CodeAlreadyExists, fmt.Errorf("directory %s already exists. Use --force to overwrite", outputDir))
 	}
 
 	// Resolve template
-	var templateFS fs.FS
-	var template *loader.Template
+	var src *templateSource
 	var err error
 
 	if config.Template != "" {
 		// External template
-		if err := rules.ValidateTemplateName(config.Template); err != nil {
-			return err
-		}
-
-		templatePath, err := resolver.Resolve(config.Template)
-		if err != nil {
-			return fmt.Errorf("failed to resolve template: %w", err)
-		}
-
-		template, err = loader.LoadFromPath(templatePath)
+		src, err = loadExternal(config.Template)
 		if err != nil {
-			return fmt.Errorf("failed to load template: %w", err)
+			return err
 		}
-
-		templateFS = os.DirFS(templatePath)
 	} else {
 		// Embedded template
 		if config.Type == "" {
-			return fmt.Errorf("either specify a type (api, worker, cli) or use --template")
+			return newError(CodeUsage, fmt.Errorf("either specify a type (api, worker, cli) or use --template"))
 		}
 
 		if err := rules.ValidateType(config.Type); err != nil {
-			return err
+			return newError(CodeInvalidInput, err)
 		}
 
-		cat := catalog.New()
-		embeddedFS, ok := cat.GetEmbeddedTemplate(config.Type)
-		if !ok {
-			return fmt.Errorf("template type %s not found", config.Type)
-		}
-
-		template, err = loader.LoadFromFS(embeddedFS)
+		src, err = loadBuiltIn(config.Type)
 		if err != nil {
-			return fmt.Errorf("failed to load template: %w", err)
+			return err
 		}
 
 		// Validate type compatibility
-		if err := rules.ValidateTypeCompatibility(template.Types, config.Type); err != nil {
-			return err
+		if err := rules.ValidateTypeCompatibility(src.template.Types, config.Type); err != nil {
+			return newError(CodeInvalidInput, err)
 		}
+	}
 
-		templateFS = embeddedFS
+	// Follow extends: the base template's files are rendered first
+	comp, err := composeTemplate(src)
+	if err != nil {
+		return err
 	}
+	template := comp.template
 
 	// Prepare render context
-	goVersion := template.Defaults["goVersion"]
-	if goVersion == "" {
-		goVersion = "1.23"
+	answers, err := readAnswers(config.Answers)
+	if err != nil {
+		return err
+	}
+	ctx, err := newRenderContext(config, template, answers)
+	if err != nil {
+		return err
 	}
+	renderer.SetAllowEnv(config.AllowEnv)
 
-	modulePlaceholder := template.Files.ModulePlaceholder
-	if modulePlaceholder == "" && config.Template != "" {
-		modulePlaceholder = "github.com/your-org/your-app"
+	// Hooks are confirmed before anything is written
+	templateHooks, err := renderHooks(template, ctx)
+	if err != nil {
+		return err
+	}
+	runHooks, err := confirmHooks(config, comp, templateHooks)
+	if err != nil {
+		return err
 	}
 
-	ctx := renderer.Context{
-		ProjectName:       config.ProjectName,
-		Module:            config.Module,
-		GoVersion:         goVersion,
-		ModulePlaceholder: modulePlaceholder,
+	// Replace the existing project only now that nothing can stop the
+	// generation before it starts
+	if config.Force && writer.DirectoryExists(outputDir) {
+		if err := os.RemoveAll(outputDir); err != nil {
+			return fmt.Errorf("failed to remove existing directory: %w", err)
+		}
 	}
 
 	// Create output directory
@@ -814,54 +1145,79 @@ func executeInit(config *Config) error {
 		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	if err := renderer.Render(templateFS, ctx, absOutputDir); err != nil {
+	var hookResults []hooks.Result
+	if runHooks {
+		results, err := runTemplateHooks(hooks.PreRender, templateHooks.PreRender, ctx, absOutputDir)
+		hookResults = append(hookResults, results...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := renderer.RenderLayers(comp.layers, ctx, absOutputDir); err != nil {
+		// Do not leave a half-generated project behind
+		_ = os.RemoveAll(absOutputDir)
+		var renderErrs renderer.Errors
+		if errors.As(err, &renderErrs) {
+			return newError(CodeRenderFailed, fmt.Errorf("failed to render template: %w", err))
+		}
 		return fmt.Errorf("failed to render template: %w", err)
 	}
 
+	if runHooks {
+		results, err := runTemplateHooks(hooks.PostRender, templateHooks.PostRender, ctx, absOutputDir)
+		hookResults = append(hookResults, results...)
+		if err != nil {
+			return fmt.Errorf("%w; the project was generated in %s", err, absOutputDir)
+		}
+	}
+
+	if structuredOutput() {
+		return writeStructured(os.Stdout, initResult{
+			Project:  config.ProjectName,
+			Path:     absOutputDir,
+			Module:   config.Module,
+			Type:     config.Type,
+			Template: config.Template,
+			Hooks:    hookResults,
+		})
+	}
 	fmt.Printf("%s Project %s initialized successfully!\n", green+"✓"+reset, accent(config.ProjectName))
 	return nil
 }
 
-func parseInitCommand(args []string) (*Config, error) {
-	fs := flag.NewFlagSet("init", flag.ContinueOnError)
-	fs.Usage = func() { printInitUsage(os.Stdout) }
-	module := fs.String("module", "", "Go module path (required)")
-	template := fs.String("template", "", "External template name")
-	force := fs.Bool("force", false, "Overwrite existing directory")
-
-	if len(args) == 0 {
-		return nil, fmt.Errorf("project name is required")
-	}
-
+func parseInitCommand(in *invocation) (*Config, error) {
 	var config Config
-	var flagArgs []string
-
-	// Parse positionals first so flags can appear after them (e.g. cosmos init api myapp --module x).
-	// Go's flag package stops at the first non-flag, so we must split positionals from flag args ourselves.
-	if len(args) >= 2 && isValidType(args[0]) {
-		config.Type = args[0]
-		config.ProjectName = args[1]
-		flagArgs = args[2:]
-	} else if len(args) >= 1 {
-		config.ProjectName = args[0]
-		flagArgs = args[1:]
-	}
-
-	if err := fs.Parse(flagArgs); err != nil {
-		return nil, err
+	switch len(in.args) {
+	case 0:
+		return nil, fmt.Errorf("project name is required")
+	case 1:
+		config.ProjectName = in.args[0]
+	default:
+		if !isValidType(in.args[0]) {
+			return nil, fmt.Errorf("invalid type: %s. Valid types: api, worker, cli", in.args[0])
+		}
+		config.Type = in.args[0]
+		config.ProjectName = in.args[1]
 	}
 
-	if config.Type == "" && *template == "" {
+	template := in.String("template")
+	if config.Type == "" && template == "" {
 		return nil, fmt.Errorf("either specify a type (api, worker, cli) or use --template")
 	}
 
-	if *module == "" {
+	module := in.String("module")
+	if module == "" {
 		return nil, fmt.Errorf("--module is required")
 	}
 
-	config.Module = *module
-	config.Template = *template
-	config.Force = *force
+	config.Module = module
+	config.Template = template
+	config.Force = in.Bool("force")
+	config.NoHooks = in.Bool("no-hooks")
+	config.TrustHooks = in.Bool("trust-hooks")
+	config.AllowEnv = in.Bool("allow-env")
+	config.Answers = in.String("answers")
 
 	return &config, nil
 }
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cosmos-toolkit/cli/internal/catalog"
+	"github.com/cosmos-toolkit/cli/internal/resolver"
+)
+
+// completeCommand is the hidden entry point used by the generated shell
+// scripts: "cosmos __complete <words...>" prints one candidate per line for
+// the last word. It only reads the embedded catalog and local caches.
+const completeCommand = "__complete"
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+const bashCompletion = `# bash completion for cosmos
+# Load with: source <(cosmos completion bash)
+_cosmos() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    COMPREPLY=($(compgen -W "$(cosmos __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)" -- "$cur"))
+}
+complete -o default -F _cosmos cosmos
+`
+
+const zshCompletion = `#compdef cosmos
+# zsh completion for cosmos
+# Load with: source <(cosmos completion zsh)
+# or save as _cosmos in a directory of your $fpath.
+_cosmos() {
+    local -a candidates
+    candidates=("${(@f)$(cosmos __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    compadd -a candidates
+}
+if [ "$funcstack[1]" = "_cosmos" ]; then
+    _cosmos "$@"
+else
+    compdef _cosmos cosmos
+fi
+`
+
+const fishCompletion = `# fish completion for cosmos
+# Load with: cosmos completion fish | source
+function __cosmos_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    cosmos __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c cosmos -f -a '(__cosmos_complete)'
+`
+
+func runCompletion(in *invocation) error {
+	var script string
+	switch in.args[0] {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return newError(CodeUsage, fmt.Errorf("unsupported shell: %s. Supported shells: bash, zsh, fish", in.args[0]))
+	}
+	_, err := io.WriteString(os.Stdout, script)
+	return err
+}
+
+// runComplete prints completion candidates for words (see completeCommand).
+func runComplete(root *command, words []string) error {
+	for _, c := range root.completions(words) {
+		fmt.Fprintln(os.Stdout, c)
+	}
+	return nil
+}
+
+// templateTypes returns the built-in template types from the embedded catalog.
+func templateTypes() []string {
+	return catalog.New().ListEmbeddedTypes()
+}
+
+func completeShells(pos int) []string {
+	if pos == 0 {
+		return completionShells
+	}
+	return nil
+}
+
+// completeInitArgs completes the [type] positional of 'cosmos init'.
+func completeInitArgs(pos int) []string {
+	if pos == 0 {
+		return templateTypes()
+	}
+	return nil
+}
+
+func completePackages(pos int) []string {
+	return resolver.CachedPackages()
+}
+
+func printCompletionUsage(w io.Writer) {
+	printBanner(w)
+	fmt.Fprintf(w, `%s
+
+  %s completion %s    Print the bash completion script
+  %s completion %s     Print the zsh completion script
+  %s completion %s    Print the fish completion script
+
+  Template names, package names and types are completed from the built-in
+  catalog and the local caches (no network access).
+
+%s
+  %s source <(cosmos completion bash)     %s
+  %s source <(cosmos completion zsh)      %s
+  %s cosmos completion fish | source      %s
+
+`,
+		title("Generate shell completion scripts."),
+		cmd("cosmos"), accent("bash"),
+		cmd("cosmos"), accent("zsh"),
+		cmd("cosmos"), accent("fish"),
+		section("EXAMPLES:"),
+		dimmed("$"), dimmed("# ~/.bashrc"),
+		dimmed("$"), dimmed("# ~/.zshrc"),
+		dimmed("$"), dimmed("# ~/.config/fish/config.fish"),
+	)
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cosmos-toolkit/cli/internal/doctor"
+)
+
+type doctorReport struct {
+	OK     bool           `json:"ok" yaml:"ok"`
+	Checks []doctor.Check `json:"checks" yaml:"checks"`
+}
+
+func runDoctor(*invocation) error {
+	if !structuredOutput() {
+		printBanner(os.Stdout)
+		fmt.Printf("%s\n\n", title("Checking your environment"))
+	}
+
+	checks := doctor.Run()
+	failed := doctor.Failed(checks)
+
+	if structuredOutput() {
+		if err := writeStructured(os.Stdout, doctorReport{OK: !failed, Checks: checks}); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range checks {
+			fmt.Printf("  %s %-26s %s\n", statusMark(c.Status), c.Name, dimmed(c.Detail))
+			if c.Fix != "" {
+				fmt.Printf("    %s %s\n", yellow+"fix:"+reset, c.Fix)
+			}
+		}
+		fmt.Println()
+	}
+
+	if failed {
+		return silent(newError(CodeEnvironment, fmt.Errorf("doctor found problems")))
+	}
+	if !structuredOutput() {
+		fmt.Printf("%s No problems found\n", green+"✓"+reset)
+	}
+	return nil
+}
+
+func statusMark(s doctor.Status) string {
+	switch s {
+	case doctor.OK:
+		return green + "✓" + reset
+	case doctor.Warn:
+		return yellow + "!" + reset
+	default:
+		return red + "✗" + reset
+	}
+}
+
+func printDoctorUsage(w io.Writer) {
+	printBanner(w)
+	fmt.Fprintf(w, `%s
+
+  %s doctor    Check git, Go, the local caches, GitHub API access and the config file
+
+  Each problem is printed with a suggested fix. The exit status is non-zero
+  when a check fails; warnings do not change it.
+
+  Checks:
+    git              present in PATH, version %s or newer (sparse-checkout add)
+    go               present in PATH, version 1.23 or newer
+    caches           templates/packages _repo is a valid git repo with sparse-checkout
+    github api       reachable, remaining rate limit with and without GITHUB_TOKEN
+    config           the config file parses and has valid values
+
+`,
+		title("Diagnose the environment."),
+		cmd("cosmos"),
+		accent("2.26"),
+	)
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cosmos-toolkit/cli/internal/provider"
+	"github.com/cosmos-toolkit/cli/internal/renderer"
+	"github.com/cosmos-toolkit/cli/internal/resolver"
+)
+
+// Error codes are stable identifiers for scripts consuming --output json|yaml.
+// Do not rename them; add new codes instead.
+const (
+	CodeUsage         = "usage"
+	CodeInvalidInput  = "invalid_input"
+	CodeNotFound      = "not_found"
+	CodeNotCached     = "not_cached"
+	CodeAlreadyExists = "already_exists"
+	CodeNetwork       = "network"
+	CodeCancelled     = "cancelled"
+	CodeEnvironment   = "environment"
+	CodeLocked        = "locked"
+	CodeAuth          = "auth"
+	CodeRateLimited   = "rate_limited"
+	CodeHookFailed    = "hook_failed"
+	CodeRenderFailed  = "render_failed"
+	CodeTestFailed    = "test_failed"
+	CodeInternal      = "internal"
+)
+
+// Error is an error with a stable code.
+type Error struct {
+	Code string
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// resolveErrorCode classifies resolver failures: missing offline content
+// is not_cached, a cache held by another process is locked, git failures
+// follow their classified cause, anything else is treated as a network
+// problem.
+func resolveErrorCode(err error) string {
+	if errors.Is(err, resolver.ErrNotCached) || errors.Is(err, resolver.ErrOffline) {
+		return CodeNotCached
+	}
+	if errors.Is(err, resolver.ErrLocked) {
+		return CodeLocked
+	}
+	if errors.Is(err, provider.ErrNotFound) {
+		return CodeNotFound
+	}
+	if errors.Is(err, provider.ErrRateLimited) {
+		return CodeRateLimited
+	}
+	if errors.Is(err, provider.ErrUnauthorized) {
+		return CodeAuth
+	}
+	if code, ok := gitErrorCode(err); ok {
+		return code
+	}
+	return CodeNetwork
+}
+
+// gitErrorCode maps the cause of a failed git command to an error code.
+func gitErrorCode(err error) (string, bool) {
+	var gerr *resolver.GitError
+	if !errors.As(err, &gerr) {
+		return "", false
+	}
+	switch gerr.Cause {
+	case resolver.CauseAuth:
+		return CodeAuth, true
+	case resolver.CauseRefNotFound:
+		return CodeNotFound, true
+	case resolver.CauseNotRepo:
+		return CodeEnvironment, true
+	}
+	return CodeNetwork, true
+}
+
+// silentError marks a failure whose details were already printed (e.g. the
+// doctor report); PrintError stays quiet and only the exit status reports it.
+type silentError struct{ error }
+
+func (e silentError) Unwrap() error { return e.error }
+
+func silent(err error) error { return silentError{err} }
+
+func newError(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// ErrorCode returns the code attached to err, or CodeInternal when none is
+// (CodeLocked for a cache lock timeout).
+func ErrorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	if errors.Is(err, resolver.ErrLocked) {
+		return CodeLocked
+	}
+	if errors.Is(err, provider.ErrRateLimited) {
+		return CodeRateLimited
+	}
+	if errors.Is(err, provider.ErrUnauthorized) {
+		return CodeAuth
+	}
+	return CodeInternal
+}
+
+type errorBody struct {
+	Code    string `json:"code" yaml:"code"`
+	Message string `json:"message" yaml:"message"`
+	// Details lists the files that failed to render, one per entry.
+	Details []string `json:"details,omitempty" yaml:"details,omitempty"`
+}
+
+// PrintError reports err to the user: as "Error: ..." on stderr in table
+// mode, or as a {"error": {"code", "message", "details"}} document on
+// stdout otherwise. Tokens are redacted from the message.
+func PrintError(err error) {
+	if errors.As(err, new(silentError)) {
+		return
+	}
+	msg := provider.Redact(err.Error())
+	if !structuredOutput() {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
+		return
+	}
+	body := errorBody{Code: ErrorCode(err), Message: msg}
+	var renderErrs renderer.Errors
+	if errors.As(err, &renderErrs) {
+		for _, fe := range renderErrs {
+			body.Details = append(body.Details, provider.Redact(fe.Error()))
+		}
+	}
+	doc := map[string]errorBody{"error": body}
+	if werr := writeStructured(os.Stdout, doc); werr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
+	}
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/cosmos-toolkit/cli/internal/hooks"
+	"github.com/cosmos-toolkit/cli/internal/loader"
+	"github.com/cosmos-toolkit/cli/internal/renderer"
+	"github.com/cosmos-toolkit/cli/internal/resolver"
+	"golang.org/x/term"
+)
+
+// renderHooks renders the hook commands of t with ctx, like file names.
+func renderHooks(t *loader.Template, ctx renderer.Context) (loader.Hooks, error) {
+	var h loader.Hooks
+	for _, stage := range []struct {
+		name     hooks.Stage
+		commands []string
+		out      *[]string
+	}{
+		{hooks.PreRender, t.Hooks.PreRender, &h.PreRender},
+		{hooks.PostRender, t.Hooks.PostRender, &h.PostRender},
+	} {
+		for _, c := range stage.commands {
+			r, err := renderer.RenderString(c, ctx)
+			if err != nil {
+				return h, newError(CodeInvalidInput, fmt.Errorf("hooks.%s: %w", stage.name, err))
+			}
+			*stage.out = append(*stage.out, r)
+		}
+	}
+	return h, nil
+}
+
+// confirmHooks decides whether hooks h run. Hooks of built-in templates
+// always do; hooks declared by an external template run once the user
+// accepts them, or with --trust-hooks when cosmos cannot ask.
+func confirmHooks(config *Config, comp *composition, h loader.Hooks) (bool, error) {
+	if len(h.PreRender)+len(h.PostRender) == 0 {
+		return false, nil
+	}
+	if config.NoHooks {
+		status("%s Hooks skipped (--no-hooks)\n", yellow+"!"+reset)
+		return false, nil
+	}
+	if len(comp.externalHooks) == 0 || config.TrustHooks {
+		return true, nil
+	}
+	if structuredOutput() || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, newError(CodeUsage, fmt.Errorf("template declares hooks that run shell commands; pass --trust-hooks to run them or --no-hooks to skip them"))
+	}
+
+	fmt.Println(section("This template runs the following commands in the project directory:"))
+	for _, c := range h.PreRender {
+		fmt.Printf("  %s %s\n", dimmed("before:"), c)
+	}
+	for _, c := range h.PostRender {
+		fmt.Printf("  %s %s\n", dimmed("after: "), c)
+	}
+	var accept bool
+	if err := survey.AskOne(&survey.Confirm{Message: "Run these hooks?", Default: false}, &accept); err != nil {
+		return false, err
+	}
+	if !accept {
+		status("%s Hooks skipped\n", yellow+"!"+reset)
+	}
+	return accept, nil
+}
+
+// runTemplateHooks runs the commands of stage in dir, printing one line per
+// command in table mode. A failure is returned with the hook_failed code.
+func runTemplateHooks(stage hooks.Stage, commands []string, ctx renderer.Context, dir string) ([]hooks.Result, error) {
+	if len(commands) == 0 {
+		return nil, nil
+	}
+
+	status("%s\n", section(fmt.Sprintf("Running %s hooks", stage)))
+	results, ok := hooks.Run(stage, commands, dir, hookEnv(ctx, dir), printHookResult)
+	if !ok {
+		for _, r := range results {
+			if r.Status == hooks.StatusFailed {
+				return results, newError(CodeHookFailed, fmt.Errorf("%s hook %q failed: %s", stage, r.Command, r.Error))
+			}
+		}
+	}
+	return results, nil
+}
+
+// hookEnv is the environment of hooks run in the project directory dir.
+func hookEnv(ctx renderer.Context, dir string) []string {
+	env := append(os.Environ(),
+		"COSMOS_PROJECT_NAME="+ctx.ProjectName,
+		"COSMOS_MODULE="+ctx.Module,
+		"COSMOS_PROJECT_DIR="+dir,
+	)
+	if resolver.IsOffline() {
+		// Offline: go commands may only use the local module cache.
+		env = append(env, "GOPROXY=off")
+	}
+	return env
+}
+
+func printHookResult(r hooks.Result) {
+	switch r.Status {
+	case hooks.StatusOK:
+		status("  %s %s %s\n", green+"✓"+reset, r.Command, dimmed(fmt.Sprintf("(%dms)", r.DurationMS)))
+	case hooks.StatusFailed:
+		status("  %s %s %s\n", red+"✗"+reset, r.Command, dimmed("("+r.Error+")"))
+		if len(r.Output) > 0 {
+			status("      %s\n", strings.Join(r.Output, "\n      "))
+		}
+	default:
+		status("  %s %s %s\n", yellow+"-"+reset, r.Command, dimmed("(skipped)"))
+	}
+}
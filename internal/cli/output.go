@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cosmos-toolkit/cli/internal/hooks"
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormat selects how command results and errors are printed.
+// Table is the human-readable default; json and yaml are meant for scripts.
+type outputFormat string
+
+const (
+	outputTable outputFormat = "table"
+	outputJSON  outputFormat = "json"
+	outputYAML  outputFormat = "yaml"
+)
+
+// output is the format selected with the global --output flag.
+var output = outputTable
+
+func parseOutputFormat(s string) (outputFormat, error) {
+	switch f := outputFormat(strings.ToLower(strings.TrimSpace(s))); f {
+	case outputTable, outputJSON, outputYAML:
+		return f, nil
+	}
+	return "", newError(CodeUsage, fmt.Errorf("invalid --output %q. Valid formats: table, json, yaml", s))
+}
+
+// setOutputFormat switches the global output format. Structured formats
+// disable colors so that nothing but the document is written to stdout.
+func setOutputFormat(f outputFormat) {
+	output = f
+	if structuredOutput() {
+		disableColors()
+	}
+}
+
+// structuredOutput reports whether results must be printed as json or yaml.
+func structuredOutput() bool {
+	return output == outputJSON || output == outputYAML
+}
+
+// writeStructured encodes v as json or yaml according to the output format.
+func writeStructured(w io.Writer, v any) error {
+	switch output {
+	case outputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode yaml: %w", err)
+		}
+		return enc.Close()
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode json: %w", err)
+		}
+		return nil
+	}
+}
+
+// requireInteractive fails when an interactive flow is started in a
+// structured output mode, where prompts would corrupt the document.
+func requireInteractive() error {
+	if structuredOutput() {
+		return newError(CodeUsage, fmt.Errorf("interactive mode is not available with --output %s; pass the arguments explicitly", output))
+	}
+	return nil
+}
+
+// subprocessOutput is where go/git subprocess stdout goes: stdout for
+// humans, stderr when stdout is reserved for a json/yaml document.
+func subprocessOutput() io.Writer {
+	if structuredOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// status prints a progress line in table mode only.
+func status(format string, a ...any) {
+	if !structuredOutput() {
+		fmt.Printf(format, a...)
+	}
+}
+
+// Structured results of commands that change state.
+
+type initResult struct {
+	Project  string         `json:"project" yaml:"project"`
+	Path     string         `json:"path" yaml:"path"`
+	Module   string         `json:"module" yaml:"module"`
+	Type     string         `json:"type,omitempty" yaml:"type,omitempty"`
+	Template string         `json:"template,omitempty" yaml:"template,omitempty"`
+	Hooks    []hooks.Result `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+}
+
+type installedPackage struct {
+	Name string `json:"name" yaml:"name"`
+	Path string `json:"path" yaml:"path"`
+}
+
+type pkgResult struct {
+	Installed []installedPackage `json:"installed" yaml:"installed"`
+}
+
+type updateResult struct {
+	Templates bool `json:"templatesUpdated" yaml:"templatesUpdated"`
+	Packages  bool `json:"packagesUpdated" yaml:"packagesUpdated"`
+}
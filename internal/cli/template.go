@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+
+	"github.com/cosmos-toolkit/cli/internal/catalog"
+	"github.com/cosmos-toolkit/cli/internal/loader"
+	"github.com/cosmos-toolkit/cli/internal/renderer"
+	"github.com/cosmos-toolkit/cli/internal/resolver"
+	"github.com/cosmos-toolkit/cli/internal/rules"
+)
+
+const (
+	// defaultModulePlaceholder is the module path external templates are
+	// written with, unless files.modulePlaceholder says otherwise.
+	defaultModulePlaceholder = "github.com/your-org/your-app"
+	// maxExtendsDepth bounds template inheritance chains.
+	maxExtendsDepth = 8
+)
+
+// templateSource is a template's files and its template.yaml.
+type templateSource struct {
+	name string
+	fs   fs.FS
+	// dir is the directory of an external template ("" for built-ins).
+	dir      string
+	template *loader.Template
+	external bool
+}
+
+// loadBuiltIn loads the embedded template of type name.
+func loadBuiltIn(name string) (*templateSource, error) {
+	embeddedFS, ok := catalog.New().GetEmbeddedTemplate(name)
+	if !ok {
+		return nil, newError(CodeNotFound, fmt.Errorf("template type %s not found", name))
+	}
+	t, err := loader.LoadFromFS(embeddedFS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load template: %w", err)
+	}
+	return &templateSource{name: name, fs: embeddedFS, template: t}, nil
+}
+
+// loadExternal fetches (or reuses from the cache) the external template
+// name and loads it.
+func loadExternal(name string) (*templateSource, error) {
+	if err := rules.ValidateTemplateName(name); err != nil {
+		return nil, newError(CodeInvalidInput, err)
+	}
+	templatePath, err := resolver.Resolve(name)
+	if err != nil {
+		return nil, newError(resolveErrorCode(err), fmt.Errorf("failed to resolve template: %w", err))
+	}
+	t, err := loader.LoadFromPath(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load template: %w", err)
+	}
+	return &templateSource{name: name, fs: renderer.DirFS(templatePath), dir: templatePath, template: t, external: true}, nil
+}
+
+// composition is a template with its extends chain resolved.
+type composition struct {
+	// layers are rendered in order, base first.
+	layers []renderer.Layer
+	// template is the template.yaml the chain adds up to.
+	template *loader.Template
+	// externalHooks are the external templates of the chain that declare
+	// hooks, which then need the user's confirmation.
+	externalHooks []*templateSource
+}
+
+// composeTemplate follows the extends chain of src. extends names a
+// built-in type when one exists, else an external template.
+func composeTemplate(src *templateSource) (*composition, error) {
+	chain := []*templateSource{src}
+	seen := map[string]bool{sourceKey(src): true}
+	for cur := src; cur.template.Extends != ""; {
+		if len(chain) > maxExtendsDepth {
+			return nil, newError(CodeInvalidInput, fmt.Errorf("template %s: extends chain is deeper than %d", src.name, maxExtendsDepth))
+		}
+		parentName := cur.template.Extends
+		var parent *templateSource
+		var err error
+		if isValidType(parentName) {
+			parent, err = loadBuiltIn(parentName)
+		} else {
+			parent, err = loadExternal(parentName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("template %s extends %s: %w", cur.name, parentName, err)
+		}
+		if seen[sourceKey(parent)] {
+			return nil, newError(CodeInvalidInput, fmt.Errorf("template %s: extends cycle through %s", src.name, parentName))
+		}
+		seen[sourceKey(parent)] = true
+		chain = append(chain, parent)
+		cur = parent
+	}
+
+	c := &composition{template: chain[len(chain)-1].template}
+	var engine renderer.Engine
+	for i := len(chain) - 1; i >= 0; i-- {
+		s := chain[i]
+		if i < len(chain)-1 {
+			c.template = loader.Inherit(c.template, s.template)
+		}
+		// A template without files.engine renders with its base's engine
+		if s.template.Files.Engine != "" || engine == nil {
+			e, err := renderer.NewEngine(s.template.Files)
+			if err != nil {
+				return nil, newError(CodeInvalidInput, fmt.Errorf("template %s: %w", s.name, err))
+			}
+			engine = e
+		}
+		sl, err := sourceLayers(s, engine)
+		if err != nil {
+			return nil, err
+		}
+		c.layers = append(c.layers, sl...)
+		if s.external && len(s.template.Hooks.PreRender)+len(s.template.Hooks.PostRender) > 0 {
+			c.externalHooks = append(c.externalHooks, s)
+		}
+	}
+	return c, nil
+}
+
+func sourceKey(s *templateSource) string {
+	if s.external {
+		return "external:" + s.name
+	}
+	return "builtin:" + s.name
+}
+
+// sourceLayers returns the layers of one template: its files without the
+// overlay directories, then each overlay, all rendered with engine.
+func sourceLayers(s *templateSource, engine renderer.Engine) ([]renderer.Layer, error) {
+	placeholder := s.template.Files.ModulePlaceholder
+	if placeholder == "" && s.external {
+		placeholder = defaultModulePlaceholder
+	}
+	rules := renderer.NewRules(s.template.Files)
+	layers := []renderer.Layer{{FS: s.fs, ModulePlaceholder: placeholder, Skip: s.template.Overlays, Engine: engine, Rules: rules, Name: s.name}}
+	for _, o := range s.template.Overlays {
+		var sub fs.FS
+		var err error
+		if s.dir != "" {
+			// Read from disk so that the overlay's symlinks are kept
+			sub = renderer.DirFS(filepath.Join(s.dir, filepath.FromSlash(path.Clean(o))))
+		} else {
+			sub, err = fs.Sub(s.fs, path.Clean(o))
+		}
+		if err == nil {
+			_, err = fs.Stat(sub, ".")
+		}
+		if err != nil {
+			return nil, newError(CodeInvalidInput, fmt.Errorf("template %s: overlay %s: %w", s.name, o, err))
+		}
+		layers = append(layers, renderer.Layer{FS: sub, ModulePlaceholder: placeholder, Engine: engine, Rules: rules, Name: s.name, Dir: path.Clean(o)})
+	}
+	return layers, nil
+}
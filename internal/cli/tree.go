@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// command is a node of the CLI command tree. Leaf commands set run; group
+// commands (list, cache, ...) only route to their subcommands.
+type command struct {
+	name    string
+	aliases []string
+	short   string // one-line description, shown in generated help
+	// usage prints the detailed help for --help/-h. When nil a summary is
+	// generated from short, flags and subcommands.
+	usage func(io.Writer)
+	flags []*flagDef
+	// minArgs/maxArgs bound the positional arguments; maxArgs < 0 means unlimited.
+	minArgs, maxArgs int
+	// complete returns shell completion candidates for positional argument pos.
+	complete func(pos int) []string
+	run      func(in *invocation) error
+	sub      []*command
+	hidden   bool
+	parent   *command
+}
+
+// flagDef declares a flag. Long names are accepted as --name, -name,
+// --name=value and --name value; short names as -x.
+type flagDef struct {
+	name  string
+	short string
+	// value is the placeholder shown in help. Flags without one are booleans.
+	value string
+	help  string
+	// persistent flags are also accepted by every subcommand (e.g. --output).
+	persistent bool
+	complete   func() []string
+}
+
+func (f *flagDef) isBool() bool { return f.value == "" }
+
+// invocation is the result of parsing argv against the tree.
+type invocation struct {
+	cmd    *command
+	args   []string
+	values map[string]string
+	help   bool
+}
+
+// String returns the value of a string flag ("" when not set).
+func (in *invocation) String(name string) string { return in.values[name] }
+
+// Bool reports whether a boolean flag was set to true.
+func (in *invocation) Bool(name string) bool {
+	v, ok := in.values[name]
+	return ok && v == "true"
+}
+
+// Changed reports whether a flag was given on the command line.
+func (in *invocation) Changed(name string) bool {
+	_, ok := in.values[name]
+	return ok
+}
+
+// ownFlagsChanged reports whether a flag of the command itself, other than
+// those in except, was given. Persistent flags of ancestors (--output,
+// --offline) do not count.
+func (in *invocation) ownFlagsChanged(except ...*flagDef) bool {
+flags:
+	for _, f := range in.cmd.flags {
+		if !in.Changed(f.name) {
+			continue
+		}
+		for _, e := range except {
+			if f == e {
+				continue flags
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (c *command) add(children ...*command) *command {
+	for _, child := range children {
+		child.parent = c
+		c.sub = append(c.sub, child)
+	}
+	return c
+}
+
+// path returns the full command path, e.g. "cosmos cache refresh".
+func (c *command) path() string {
+	if c.parent == nil {
+		return c.name
+	}
+	return c.parent.path() + " " + c.name
+}
+
+func (c *command) findSub(name string) *command {
+	for _, s := range c.sub {
+		if s.name == name {
+			return s
+		}
+		for _, a := range s.aliases {
+			if a == name {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// lookupFlag finds a flag by long or short name on c, or a persistent
+// flag on one of its ancestors.
+func (c *command) lookupFlag(name string) *flagDef {
+	for _, f := range c.visibleFlags() {
+		if f.name == name || (f.short != "" && f.short == name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// visibleFlags returns c's flags followed by persistent ancestor flags.
+func (c *command) visibleFlags() []*flagDef {
+	flags := append([]*flagDef(nil), c.flags...)
+	for n := c.parent; n != nil; n = n.parent {
+		for _, f := range n.flags {
+			if f.persistent {
+				flags = append(flags, f)
+			}
+		}
+	}
+	return flags
+}
+
+// splitFlag turns "--name=value" into ("name", "value", true) and "-x" into ("x", "", false).
+func splitFlag(arg string) (name, value string, hasValue bool) {
+	name = strings.TrimLeft(arg, "-")
+	if i := strings.IndexByte(name, '='); i >= 0 {
+		return name[:i], name[i+1:], true
+	}
+	return name, "", false
+}
+
+func isFlag(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-'
+}
+
+// parse walks args down the tree. Subcommand names are matched until the
+// first positional argument; flags may appear anywhere before "--". The
+// returned invocation is usable (for --output) even when err is set.
+func (c *command) parse(args []string) (*invocation, error) {
+	in := &invocation{cmd: c, values: make(map[string]string)}
+	flagsDone := false
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if !flagsDone && a == "--" {
+			flagsDone = true
+			continue
+		}
+		if !flagsDone && isFlag(a) {
+			name, value, hasValue := splitFlag(a)
+			if name == "help" || name == "h" {
+				in.help = true
+				continue
+			}
+			f := in.cmd.lookupFlag(name)
+			if f == nil {
+				return in, newError(CodeUsage, fmt.Errorf("unknown flag: %s\n\nRun '%s --help' for usage", a, in.cmd.path()))
+			}
+			if f.isBool() {
+				if !hasValue {
+					value = "true"
+				} else if b, err := strconv.ParseBool(value); err == nil {
+					value = strconv.FormatBool(b)
+				} else {
+					return in, newError(CodeUsage, fmt.Errorf("invalid value %q for --%s: expected true or false", value, f.name))
+				}
+			} else if !hasValue {
+				if i+1 >= len(args) {
+					return in, newError(CodeUsage, fmt.Errorf("flag --%s requires a value", f.name))
+				}
+				i++
+				value = args[i]
+			}
+			in.values[f.name] = value
+			continue
+		}
+		if len(in.args) == 0 {
+			if s := in.cmd.findSub(a); s != nil {
+				in.cmd = s
+				continue
+			}
+		}
+		in.args = append(in.args, a)
+	}
+	return in, nil
+}
+
+// execute runs the parsed invocation: help, group routing, argument
+// count checks, then the command itself.
+func (in *invocation) execute(w io.Writer) error {
+	c := in.cmd
+	if in.help {
+		c.printUsage(w)
+		return nil
+	}
+	if c.run == nil {
+		if len(in.args) > 0 {
+			return newError(CodeUsage, fmt.Errorf("unknown subcommand: %s\n\nRun '%s --help' for usage", in.args[0], c.path()))
+		}
+		c.printUsage(w)
+		return nil
+	}
+	if len(in.args) < c.minArgs {
+		return newError(CodeUsage, fmt.Errorf("%s: expected at least %d argument(s)\n\nRun '%s --help' for usage", c.path(), c.minArgs, c.path()))
+	}
+	if c.maxArgs >= 0 && len(in.args) > c.maxArgs {
+		return newError(CodeUsage, fmt.Errorf("%s: unexpected argument %q\n\nRun '%s --help' for usage", c.path(), in.args[c.maxArgs], c.path()))
+	}
+	return c.run(in)
+}
+
+func (c *command) printUsage(w io.Writer) {
+	if c.usage != nil {
+		c.usage(w)
+		return
+	}
+	printBanner(w)
+	fmt.Fprintf(w, "%s\n\n", title(c.short))
+	fmt.Fprintf(w, "%s\n  %s", section("USAGE:"), cmd(c.path()))
+	if len(c.sub) > 0 {
+		fmt.Fprintf(w, " %s", accent("<command>"))
+	}
+	fmt.Fprintf(w, " %s\n\n", flagStyle("[flags]"))
+	if len(c.sub) > 0 {
+		fmt.Fprintf(w, "%s\n", section("COMMANDS:"))
+		for _, s := range c.sub {
+			if !s.hidden {
+				fmt.Fprintf(w, "  %-14s %s\n", accent(s.name), s.short)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	if len(c.flags) > 0 {
+		fmt.Fprintf(w, "%s\n", section("FLAGS:"))
+		for _, f := range c.flags {
+			fmt.Fprintf(w, "  %s\n      %s\n", flagStyle(f.synopsis()), f.help)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func (f *flagDef) synopsis() string {
+	s := "--" + f.name
+	if f.short != "" {
+		s += ", -" + f.short
+	}
+	if !f.isBool() {
+		s += " <" + f.value + ">"
+	}
+	return s
+}
+
+// completions returns candidates for the last word of words, which is the
+// (possibly empty) word being completed. Earlier words are walked like parse.
+func (c *command) completions(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur := words[len(words)-1]
+	node := c
+	positional := 0
+	var pending *flagDef
+	for _, w := range words[:len(words)-1] {
+		if pending != nil {
+			pending = nil
+			continue
+		}
+		if isFlag(w) {
+			name, _, hasValue := splitFlag(w)
+			if f := node.lookupFlag(name); f != nil && !f.isBool() && !hasValue {
+				pending = f
+			}
+			continue
+		}
+		if positional == 0 {
+			if s := node.findSub(w); s != nil {
+				node = s
+				continue
+			}
+		}
+		positional++
+	}
+
+	var candidates []string
+	switch {
+	case pending != nil:
+		if pending.complete != nil {
+			candidates = pending.complete()
+		}
+	case strings.HasPrefix(cur, "-") && strings.Contains(cur, "="):
+		name, _, _ := splitFlag(cur)
+		prefix := cur[:strings.IndexByte(cur, '=')+1]
+		if f := node.lookupFlag(name); f != nil && f.complete != nil {
+			for _, v := range f.complete() {
+				candidates = append(candidates, prefix+v)
+			}
+		}
+	case strings.HasPrefix(cur, "-"):
+		for _, f := range node.visibleFlags() {
+			candidates = append(candidates, "--"+f.name)
+		}
+		candidates = append(candidates, "--help")
+	default:
+		if positional == 0 && len(node.sub) > 0 {
+			for _, s := range node.sub {
+				if !s.hidden {
+					candidates = append(candidates, s.name)
+				}
+			}
+		} else if node.complete != nil {
+			candidates = node.complete(positional)
+		}
+	}
+
+	out := make([]string, 0, len(candidates))
+	seen := make(map[string]bool)
+	for _, cand := range candidates {
+		if strings.HasPrefix(cand, cur) && !seen[cand] {
+			seen[cand] = true
+			out = append(out, cand)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
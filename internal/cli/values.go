@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/cosmos-toolkit/cli/internal/loader"
+	"github.com/cosmos-toolkit/cli/internal/renderer"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// readAnswers reads an answers file: a YAML map of prompt keys to values.
+func readAnswers(file string) (map[string]any, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, newError(CodeNotFound, fmt.Errorf("answers file %s not found", file))
+		}
+		return nil, err
+	}
+	var answers map[string]any
+	if err := yaml.Unmarshal(data, &answers); err != nil {
+		return nil, newError(CodeInvalidInput, fmt.Errorf("failed to parse answers file %s: %w", file, err))
+	}
+	return answers, nil
+}
+
+// newRenderContext returns the context the files of t are rendered with
+// for config, given the answers to its prompts.
+func newRenderContext(config *Config, t *loader.Template, answers map[string]any) (renderer.Context, error) {
+	goVersion := t.Defaults["goVersion"]
+	if goVersion == "" {
+		goVersion = "1.23"
+	}
+	values, err := resolveValues(config, t, answers)
+	if err != nil {
+		return renderer.Context{}, err
+	}
+	return renderer.Context{
+		ProjectName: config.ProjectName,
+		Module:      config.Module,
+		GoVersion:   goVersion,
+		Values:      values,
+	}, nil
+}
+
+// resolveValues returns the values templates see as .Values: each prompt's
+// answer from answers, else its default, else template.yaml's defaults.
+// A required prompt without a value is asked for on a terminal. module and
+// projectName are those of the command.
+func resolveValues(config *Config, t *loader.Template, answers map[string]any) (map[string]any, error) {
+	values := make(map[string]any, len(t.Defaults)+len(answers)+2)
+	for k, v := range t.Defaults {
+		values[k] = v
+	}
+	for k, v := range answers {
+		values[k] = v
+	}
+	values["module"] = config.Module
+	values["projectName"] = config.ProjectName
+
+	for _, p := range t.Prompts {
+		v, ok := values[p.Key]
+		ok = ok && v != nil
+		if !ok && p.Default != nil {
+			v, ok = p.Default, true
+		}
+		if !ok {
+			if !p.Required {
+				// Declared, so that templates can test it without
+				// tripping over a missing key
+				values[p.Key] = nil
+				continue
+			}
+			var err error
+			if v, err = askPrompt(config, p); err != nil {
+				return nil, err
+			}
+		}
+		v, err := promptValue(p, v)
+		if err != nil {
+			return nil, newError(CodeInvalidInput, err)
+		}
+		values[p.Key] = v
+	}
+	return values, nil
+}
+
+// promptValue converts v to the type of p. Lists may be given as a
+// comma-separated string.
+func promptValue(p loader.Prompt, v any) (any, error) {
+	switch p.Type {
+	case loader.PromptList:
+		switch v := v.(type) {
+		case []any:
+			return v, nil
+		case string:
+			var list []any
+			for _, s := range strings.Split(v, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					list = append(list, s)
+				}
+			}
+			return list, nil
+		}
+	case loader.PromptBool:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+	case loader.PromptInt:
+		switch v := v.(type) {
+		case int:
+			return v, nil
+		case string:
+			if n, err := strconv.Atoi(v); err == nil {
+				return n, nil
+			}
+		}
+	default:
+		switch v.(type) {
+		case string:
+			return v, nil
+		case bool, int, float64:
+			return fmt.Sprint(v), nil
+		}
+	}
+	typ := p.Type
+	if typ == "" {
+		typ = loader.PromptString
+	}
+	return nil, fmt.Errorf("prompt %s: %v is not a valid %s", p.Key, v, typ)
+}
+
+// askPrompt asks for the value of a required prompt, when cosmos can ask.
+func askPrompt(config *Config, p loader.Prompt) (any, error) {
+	if config.NoPrompt || structuredOutput() || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, newError(CodeUsage, fmt.Errorf("prompt %s requires a value; pass it in an --answers file", p.Key))
+	}
+	message := p.Description
+	if message == "" {
+		message = p.Key
+	}
+	if p.Type == loader.PromptBool {
+		var b bool
+		err := survey.AskOne(&survey.Confirm{Message: message}, &b)
+		return b, err
+	}
+	if p.Type == loader.PromptList {
+		message += " (comma-separated)"
+	}
+	var s string
+	err := survey.AskOne(&survey.Input{Message: message}, &s, survey.WithValidator(survey.Required))
+	return s, err
+}
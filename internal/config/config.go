@@ -0,0 +1,186 @@
+// Package config loads the optional user configuration file.
+//
+// The file is looked up at $COSMOS_CONFIG, then $XDG_CONFIG_HOME/cosmos/config.yaml,
+// then ~/.config/cosmos/config.yaml. A missing file is not an error.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the content of config.yaml. Every field is optional.
+type Config struct {
+	// Output is the default output format when --output is not given (table, json, yaml).
+	Output string `yaml:"output"`
+	// Offline makes every command use only the local caches (like --offline).
+	Offline bool `yaml:"offline"`
+	// CacheTTL is how long the template and package caches are used before
+	// being pulled again, as a Go duration ("24h", "30m"; "0" pulls every time).
+	CacheTTL string `yaml:"cacheTTL"`
+	// Fetcher selects how caches are populated: auto (git when installed),
+	// git, or archive (tarball downloads, no git needed).
+	Fetcher string `yaml:"fetcher"`
+	// Ref is the branch or tag of the templates and packages repositories
+	// to fetch (default: their default branch).
+	Ref string `yaml:"ref"`
+	// Registries replace the default repositories (cosmos-toolkit/templates
+	// and cosmos-toolkit/packages on GitHub).
+	Registries Registries `yaml:"registries"`
+}
+
+// Registries holds the repository of templates and the one of packages.
+type Registries struct {
+	Templates *Registry `yaml:"templates"`
+	Packages  *Registry `yaml:"packages"`
+}
+
+// Registry locates a repository.
+type Registry struct {
+	// Type is github (default), gitlab, gitea (also Forgejo) or git.
+	Type string `yaml:"type"`
+	// URL is the server's web URL (e.g. https://gitlab.example.com); for
+	// type git it is the clone URL of the repository.
+	URL string `yaml:"url"`
+	// API overrides the API URL derived from URL.
+	API string `yaml:"api"`
+	// Repo is the repository path on the server ("group/name").
+	Repo string `yaml:"repo"`
+	// Ref is the branch or tag; it overrides the top-level ref.
+	Ref string `yaml:"ref"`
+	// Token authenticates API requests and HTTPS clones. Prefer a reference
+	// to an environment variable ("$MY_TOKEN") over the token itself.
+	Token string `yaml:"token"`
+	// Protocol is what git clones over: https (default) or ssh.
+	Protocol string `yaml:"protocol"`
+}
+
+// Path returns the location of the config file, whether or not it exists.
+func Path() (string, error) {
+	if p := os.Getenv("COSMOS_CONFIG"); p != "" {
+		return p, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "cosmos", "config.yaml"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".config", "cosmos", "config.yaml"), nil
+}
+
+// Load reads and validates the config file. When the file does not exist an
+// empty Config is returned.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads and validates the config file at path. Unknown keys are
+// rejected so that typos do not go unnoticed.
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Validate checks field values.
+func (c *Config) Validate() error {
+	switch c.Output {
+	case "", "table", "json", "yaml":
+	default:
+		return fmt.Errorf("output: %q is not one of table, json, yaml", c.Output)
+	}
+	switch c.Fetcher {
+	case "", "auto", "git", "archive":
+	default:
+		return fmt.Errorf("fetcher: %q is not one of auto, git, archive", c.Fetcher)
+	}
+	for name, r := range map[string]*Registry{"templates": c.Registries.Templates, "packages": c.Registries.Packages} {
+		if r == nil {
+			continue
+		}
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("registries.%s: %w", name, err)
+		}
+	}
+	if c.CacheTTL != "" {
+		if d, err := time.ParseDuration(c.CacheTTL); err != nil || d < 0 {
+			return fmt.Errorf("cacheTTL: %q is not a valid duration (e.g. 24h, 30m)", c.CacheTTL)
+		}
+	}
+	return nil
+}
+
+func (r *Registry) validate() error {
+	switch r.Type {
+	case "", "github", "gitlab", "gitea", "git":
+	default:
+		return fmt.Errorf("type: %q is not one of github, gitlab, gitea, git", r.Type)
+	}
+	if (r.Type == "gitea" || r.Type == "git") && r.URL == "" {
+		return fmt.Errorf("url is required for type %s", r.Type)
+	}
+	switch r.Protocol {
+	case "", "https", "ssh":
+	default:
+		return fmt.Errorf("protocol: %q is not one of https, ssh", r.Protocol)
+	}
+	if r.Type == "git" && r.Protocol != "" {
+		return fmt.Errorf("protocol does not apply to type git; use an ssh:// or git@ URL")
+	}
+	if r.Type == "git" && r.Token != "" {
+		return fmt.Errorf("token does not apply to type git; git reads credentials from its helpers, netrc or ssh keys")
+	}
+	if r.Type != "git" && r.Repo == "" {
+		return fmt.Errorf("repo is required")
+	}
+	return nil
+}
+
+// HasInlineToken reports whether a registry token is written in the file
+// itself rather than referenced from the environment ("$VAR").
+func (c *Config) HasInlineToken() bool {
+	for _, r := range []*Registry{c.Registries.Templates, c.Registries.Packages} {
+		if r != nil && r.Token != "" && !strings.HasPrefix(r.Token, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// TTL returns CacheTTL as a duration; ok is false when it is not set.
+func (c *Config) TTL() (d time.Duration, ok bool) {
+	if c.CacheTTL == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(c.CacheTTL)
+	return d, err == nil
+}
@@ -0,0 +1,364 @@
+// Package doctor diagnoses the environment cosmos depends on: git, the Go
+// toolchain, the local caches, the GitHub API and the config file.
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cosmos-toolkit/cli/internal/config"
+	"github.com/cosmos-toolkit/cli/internal/github"
+	"github.com/cosmos-toolkit/cli/internal/provider"
+	"github.com/cosmos-toolkit/cli/internal/registry"
+	"github.com/cosmos-toolkit/cli/internal/resolver"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	OK   Status = "ok"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Check is the result of one diagnostic. Fix tells the user how to resolve
+// a warning or failure.
+type Check struct {
+	Name   string `json:"name" yaml:"name"`
+	Status Status `json:"status" yaml:"status"`
+	Detail string `json:"detail" yaml:"detail"`
+	Fix    string `json:"fix,omitempty" yaml:"fix,omitempty"`
+}
+
+const (
+	// minGit is the first release with "git sparse-checkout add".
+	minGitMajor, minGitMinor = 2, 26
+	// minGo matches the goVersion default of the built-in templates.
+	minGoMajor, minGoMinor = 1, 23
+	// lowRateLimit is the remaining quota below which a warning is shown.
+	lowRateLimit = 10
+)
+
+var versionRegex = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Run performs every check, in display order.
+func Run() []Check {
+	gitCheck := checkGit()
+	checks := []Check{gitCheck, checkGo()}
+	gitOK := gitCheck.Status != Fail
+	checks = append(checks,
+		checkCache("templates cache", resolver.KindTemplate, gitOK),
+		checkCache("packages cache", resolver.KindPackage, gitOK),
+	)
+	if usesGitHubCom() {
+		checks = append(checks, checkGitHub()...)
+	}
+	for _, kind := range []string{registry.Templates, registry.Packages} {
+		if r := registry.Describe(kind); !isGitHubCom(r) {
+			checks = append(checks, checkRegistry(kind, r))
+		}
+	}
+	checks = append(checks, checkConfig())
+	return checks
+}
+
+// Failed reports whether any check failed.
+func Failed(checks []Check) bool {
+	for _, c := range checks {
+		if c.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+func checkGit() Check {
+	c := Check{Name: "git"}
+	if _, err := exec.LookPath("git"); err != nil {
+		if resolver.FetcherInUse() == resolver.FetcherArchive {
+			// Caches are downloaded as archives; git is optional.
+			c.Status = Warn
+			c.Detail = "git not found in PATH; templates and packages are downloaded as archives"
+			c.Fix = fmt.Sprintf("Install git %d.%d or newer for incremental updates (optional)", minGitMajor, minGitMinor)
+			return c
+		}
+		c.Status = Fail
+		c.Detail = "git not found in PATH"
+		c.Fix = fmt.Sprintf("Install git %d.%d or newer (https://git-scm.com/downloads)", minGitMajor, minGitMinor)
+		return c
+	}
+	out, err := exec.Command("git", "--version").Output()
+	if err != nil {
+		c.Status = Fail
+		c.Detail = fmt.Sprintf("git --version failed: %v", err)
+		c.Fix = "Reinstall git"
+		return c
+	}
+	raw := strings.TrimSpace(string(out))
+	major, minor, ok := parseVersion(raw)
+	if !ok {
+		c.Status = Warn
+		c.Detail = fmt.Sprintf("could not parse version from %q", raw)
+		return c
+	}
+	if !atLeast(major, minor, minGitMajor, minGitMinor) {
+		c.Status = Fail
+		c.Detail = fmt.Sprintf("%s is too old: sparse-checkout add requires %d.%d+", raw, minGitMajor, minGitMinor)
+		c.Fix = fmt.Sprintf("Upgrade git to %d.%d or newer", minGitMajor, minGitMinor)
+		return c
+	}
+	c.Status = OK
+	c.Detail = raw
+	return c
+}
+
+func checkGo() Check {
+	c := Check{Name: "go"}
+	if _, err := exec.LookPath("go"); err != nil {
+		c.Status = Fail
+		c.Detail = "go not found in PATH (needed by 'cosmos pkg' and to build generated projects)"
+		c.Fix = fmt.Sprintf("Install Go %d.%d or newer (https://go.dev/dl/)", minGoMajor, minGoMinor)
+		return c
+	}
+	out, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		c.Status = Fail
+		c.Detail = fmt.Sprintf("go env GOVERSION failed: %v", err)
+		c.Fix = "Reinstall Go"
+		return c
+	}
+	raw := strings.TrimSpace(string(out))
+	major, minor, ok := parseVersion(raw)
+	if !ok {
+		c.Status = Warn
+		c.Detail = fmt.Sprintf("could not parse version from %q", raw)
+		return c
+	}
+	if !atLeast(major, minor, minGoMajor, minGoMinor) {
+		c.Status = Warn
+		c.Detail = fmt.Sprintf("%s is older than the go %d.%d used by generated projects", raw, minGoMajor, minGoMinor)
+		c.Fix = fmt.Sprintf("Upgrade Go to %d.%d or newer", minGoMajor, minGoMinor)
+		return c
+	}
+	c.Status = OK
+	c.Detail = raw
+	return c
+}
+
+// checkCache verifies that a cache, when present, is either a git repository
+// with sparse-checkout configured or a plain directory (archive or bundle).
+func checkCache(name, kind string, gitOK bool) Check {
+	c := Check{Name: name}
+	source := resolver.CacheSource(kind)
+	path, err := resolver.RepoPath(kind)
+	if err != nil {
+		c.Status = Fail
+		c.Detail = err.Error()
+		return c
+	}
+	removeFix := fmt.Sprintf("Remove it with 'rm -rf %s'; it is re-created on next use", path)
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		c.Status = OK
+		c.Detail = fmt.Sprintf("%s not created yet (created on first use)", path)
+		return c
+	}
+	if err != nil || !info.IsDir() {
+		c.Status = Fail
+		c.Detail = fmt.Sprintf("%s is not a directory", path)
+		c.Fix = removeFix
+		return c
+	}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		// Archive downloads and bundle imports are plain directories.
+		c.Status = OK
+		if source == resolver.SourceArchive {
+			c.Detail = fmt.Sprintf("%s (downloaded as an archive)", path)
+		} else {
+			c.Detail = fmt.Sprintf("%s (imported from a bundle; not a git checkout, 'cosmos update' skips it)", path)
+		}
+		return c
+	}
+	if !gitOK {
+		c.Status = Warn
+		c.Detail = fmt.Sprintf("%s not inspected (git unavailable)", path)
+		return c
+	}
+	if err := exec.Command("git", "-C", path, "rev-parse", "--verify", "HEAD").Run(); err != nil {
+		c.Status = Fail
+		c.Detail = fmt.Sprintf("%s is a corrupt git repository (no valid HEAD)", path)
+		c.Fix = removeFix
+		return c
+	}
+	out, _ := exec.Command("git", "-C", path, "config", "--get", "core.sparseCheckout").Output()
+	if strings.TrimSpace(string(out)) != "true" {
+		c.Status = Warn
+		c.Detail = fmt.Sprintf("%s has no sparse-checkout configured (the whole repository is checked out)", path)
+		c.Fix = removeFix
+		return c
+	}
+	list, _ := exec.Command("git", "-C", path, "sparse-checkout", "list").Output()
+	entries := strings.Fields(string(list))
+	c.Status = OK
+	c.Detail = fmt.Sprintf("%s (sparse: %s)", path, strings.Join(entries, ", "))
+	return c
+}
+
+func isGitHubCom(r provider.Registry) bool {
+	return (r.Type == "" || r.Type == provider.TypeGitHub) && (r.URL == "" || r.URL == "https://github.com")
+}
+
+func usesGitHubCom() bool {
+	return isGitHubCom(registry.Describe(registry.Templates)) || isGitHubCom(registry.Describe(registry.Packages))
+}
+
+// checkRegistry checks that a registry not hosted on github.com answers a
+// listing of its root.
+func checkRegistry(kind string, r provider.Registry) Check {
+	p := registry.Get(kind)
+	c := Check{Name: "registry " + kind}
+	switch {
+	case resolver.IsOffline():
+		c.Status = OK
+		c.Detail = "skipped (offline mode)"
+		return c
+	case r.Type == provider.TypeGit:
+		c.Status = OK
+		c.Detail = fmt.Sprintf("%s (git; listed from the local checkout)", p.CloneURL())
+		return c
+	}
+	if _, err := p.ListDirs(""); err != nil {
+		c.Status = Fail
+		c.Detail = provider.Redact(fmt.Sprintf("%s: %v", p.CloneURL(), err))
+		c.Fix = fmt.Sprintf("Check registries.%s in the config file, your network connection and the registry token", kind)
+		return c
+	}
+	c.Status = OK
+	auth := "anonymous"
+	if a, ok := p.(provider.Authenticator); ok && a.Credential().Token != "" {
+		auth = "token from " + a.Credential().Source
+	}
+	c.Detail = fmt.Sprintf("%s (%s, %s)", p.CloneURL(), r.Type, auth)
+	return c
+}
+
+// checkGitHub checks API reachability and the remaining quota, anonymously
+// and, when a token is found (config, GITHUB_TOKEN, gh, git credential
+// helper or netrc), with the token. Only where it came from is shown.
+func checkGitHub() []Check {
+	if resolver.IsOffline() {
+		return []Check{{Name: "github api", Status: OK, Detail: "skipped (offline mode)"}}
+	}
+	checks := []Check{checkRateLimit("github api (anonymous)", "")}
+	cred := githubCredential()
+	if cred.Token == "" {
+		checks = append(checks, Check{
+			Name:   "github api (token)",
+			Status: Warn,
+			Detail: "no token found; anonymous requests are limited to 60 per hour",
+			Fix:    "export GITHUB_TOKEN=<token> or run 'gh auth login' (a token without scopes is enough for public repos)",
+		})
+		return checks
+	}
+	return append(checks, checkRateLimit(fmt.Sprintf("github api (%s)", cred.Source), cred.Token))
+}
+
+// githubCredential returns the credential of the first registry hosted on
+// github.com.
+func githubCredential() provider.Credential {
+	for _, kind := range []string{registry.Templates, registry.Packages} {
+		if !isGitHubCom(registry.Describe(kind)) {
+			continue
+		}
+		if a, ok := registry.Get(kind).(provider.Authenticator); ok {
+			return a.Credential()
+		}
+	}
+	return provider.Credential{}
+}
+
+func checkRateLimit(name, token string) Check {
+	c := Check{Name: name}
+	rl, err := github.GetRateLimit(token)
+	if err != nil {
+		c.Status = Fail
+		c.Detail = fmt.Sprintf("api.github.com unreachable: %s", provider.Redact(err.Error()))
+		c.Fix = "Check your network connection and proxy settings (HTTPS_PROXY)"
+		if token != "" {
+			c.Fix = "Check that the token is valid and not expired, and your network connection"
+		}
+		return c
+	}
+	c.Detail = fmt.Sprintf("%d/%d requests remaining, resets at %s", rl.Remaining, rl.Limit, rl.Reset.Format(time.Kitchen))
+	switch {
+	case rl.Remaining == 0:
+		c.Status = Fail
+		c.Fix = fmt.Sprintf("Wait until %s", rl.Reset.Format(time.Kitchen))
+		if token == "" {
+			c.Fix += " or set GITHUB_TOKEN for a higher limit"
+		}
+	case rl.Remaining < lowRateLimit:
+		c.Status = Warn
+		if token == "" {
+			c.Fix = "Set GITHUB_TOKEN for a higher limit"
+		}
+	default:
+		c.Status = OK
+	}
+	return c
+}
+
+func checkConfig() Check {
+	c := Check{Name: "config"}
+	path, err := config.Path()
+	if err != nil {
+		c.Status = Fail
+		c.Detail = err.Error()
+		return c
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		c.Status = OK
+		c.Detail = fmt.Sprintf("%s not present (optional)", path)
+		return c
+	}
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		c.Status = Fail
+		c.Detail = err.Error()
+		c.Fix = fmt.Sprintf("Fix or remove %s", path)
+		return c
+	}
+	c.Status = OK
+	c.Detail = path
+	if info, err := os.Stat(path); err == nil && cfg.HasInlineToken() && runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		c.Status = Warn
+		c.Detail = fmt.Sprintf("%s contains a token and is readable by other users", path)
+		c.Fix = fmt.Sprintf("chmod 600 %s, or reference an environment variable (token: $MY_TOKEN)", path)
+	}
+	return c
+}
+
+// parseVersion extracts major.minor from strings like "git version 2.39.3"
+// or "go1.23.4".
+func parseVersion(s string) (major, minor int, ok bool) {
+	m := versionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, false
+	}
+	major, _ = strconv.Atoi(m[1])
+	minor, _ = strconv.Atoi(m[2])
+	return major, minor, true
+}
+
+func atLeast(major, minor, wantMajor, wantMinor int) bool {
+	return major > wantMajor || (major == wantMajor && minor >= wantMinor)
+}
@@ -0,0 +1,69 @@
+// Package github queries the GitHub API quota, for 'cosmos doctor'.
+// Repository access goes through internal/provider.
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	rateLimitURL = "https://api.github.com/rate_limit"
+
+	// defaultTimeout is the timeout for GitHub API requests (avoids hanging).
+	defaultTimeout = 30 * time.Second
+)
+
+var httpClient = &http.Client{Timeout: defaultTimeout}
+
+func doRequestWithToken(ctx context.Context, url, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return httpClient.Do(req)
+}
+
+// RateLimit is the core API quota reported by GitHub.
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"-"`
+	ResetUnix int64     `json:"reset"`
+}
+
+// GetRateLimit queries the /rate_limit endpoint (which does not consume
+// quota), authenticated with token when it is not empty.
+func GetRateLimit(token string) (*RateLimit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := doRequestWithToken(ctx, rateLimitURL, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Resources struct {
+			Core RateLimit `json:"core"`
+		} `json:"resources"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	rl := body.Resources.Core
+	rl.Reset = time.Unix(rl.ResetUnix, 0)
+	return &rl, nil
+}
@@ -0,0 +1,99 @@
+// Package hooks runs the commands a template declares under hooks.pre_render
+// and hooks.post_render, in the project directory.
+package hooks
+
+import (
+	"bytes"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Stage is when a hook runs.
+type Stage string
+
+const (
+	PreRender  Stage = "pre_render"
+	PostRender Stage = "post_render"
+)
+
+// Hook statuses.
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// maxOutputLines bounds how much of a failed command's output is reported.
+const maxOutputLines = 20
+
+// Result is the outcome of one hook command. Output holds the last lines
+// the command printed when it failed.
+type Result struct {
+	Stage      Stage    `json:"stage" yaml:"stage"`
+	Command    string   `json:"command" yaml:"command"`
+	Status     string   `json:"status" yaml:"status"`
+	DurationMS int64    `json:"durationMs" yaml:"durationMs"`
+	Error      string   `json:"error,omitempty" yaml:"error,omitempty"`
+	Output     []string `json:"output,omitempty" yaml:"output,omitempty"`
+}
+
+// Run runs commands in order with the system shell (sh -c, or cmd /C on
+// Windows) in dir, with env as the environment. The first failure stops
+// the sequence: the remaining commands are reported as skipped. report,
+// when not nil, is called after each command.
+func Run(stage Stage, commands []string, dir string, env []string, report func(Result)) (results []Result, ok bool) {
+	ok = true
+	for _, command := range commands {
+		r := Result{Stage: stage, Command: command, Status: StatusSkipped}
+		if ok {
+			r = run(stage, command, dir, env)
+			ok = r.Status == StatusOK
+		}
+		if report != nil {
+			report(r)
+		}
+		results = append(results, r)
+	}
+	return results, ok
+}
+
+func run(stage Stage, command, dir string, env []string) Result {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = dir
+	cmd.Env = env
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	start := time.Now()
+	err := cmd.Run()
+	r := Result{Stage: stage, Command: command, Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		r.Status = StatusFailed
+		r.Error = err.Error()
+		r.Output = LastLines(out.String())
+	}
+	return r
+}
+
+// LastLines returns the last non-empty lines of the output s of a failed
+// command, as much of it as is worth reporting.
+func LastLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxOutputLines {
+		lines = lines[len(lines)-maxOutputLines:]
+	}
+	return lines
+}
@@ -0,0 +1,26 @@
+package hooks
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLastLines(t *testing.T) {
+	if got := LastLines(""); len(got) != 0 {
+		t.Errorf("LastLines(\"\") = %q, want none", got)
+	}
+	if got, want := LastLines("a\n\n  \nb\n"), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LastLines = %q, want %q", got, want)
+	}
+
+	var b strings.Builder
+	for i := 1; i <= maxOutputLines+5; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	got := LastLines(b.String())
+	if len(got) != maxOutputLines || got[0] != "line 6" || got[len(got)-1] != fmt.Sprintf("line %d", maxOutputLines+5) {
+		t.Errorf("LastLines kept %d lines from %q to %q, want the last %d", len(got), got[0], got[len(got)-1], maxOutputLines)
+	}
+}
@@ -0,0 +1,390 @@
+// Package lint checks a template before it is published: its template.yaml
+// against the schema, and its files against its template.yaml.
+package lint
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cosmos-toolkit/cli/internal/loader"
+	"github.com/cosmos-toolkit/cli/internal/renderer"
+	"github.com/cosmos-toolkit/cli/internal/rules"
+	"github.com/cosmos-toolkit/cli/internal/writer"
+)
+
+// Severity tells whether a finding breaks the template (Error) or is
+// probably a mistake (Warning).
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+)
+
+// Checks, as reported in Finding.Check.
+const (
+	CheckTemplateYAML = "template.yaml"
+	CheckSchema       = "schema"
+	CheckParse        = "parse"
+	CheckPartials     = "partials"
+	CheckValues       = "values"
+	CheckPrompts      = "prompts"
+	CheckFeatures     = "features"
+	CheckFiles        = "files"
+	CheckTypes        = "types"
+	CheckPlaceholder  = "placeholder"
+)
+
+const templateYAML = "template.yaml"
+
+// Finding is one problem found in a template. Path is relative to the
+// template directory; Line and Col are 0 when unknown.
+type Finding struct {
+	Severity Severity `json:"severity" yaml:"severity"`
+	Check    string   `json:"check" yaml:"check"`
+	Path     string   `json:"path,omitempty" yaml:"path,omitempty"`
+	Line     int      `json:"line,omitempty" yaml:"line,omitempty"`
+	Col      int      `json:"col,omitempty" yaml:"col,omitempty"`
+	Message  string   `json:"message" yaml:"message"`
+}
+
+// Location is path[:line[:col]].
+func (f Finding) Location() string {
+	loc := f.Path
+	if f.Line > 0 {
+		loc += fmt.Sprintf(":%d", f.Line)
+		if f.Col > 0 {
+			loc += fmt.Sprintf(":%d", f.Col)
+		}
+	}
+	return loc
+}
+
+// HasErrors reports whether any finding is an Error.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Template is the template to check.
+type Template struct {
+	// Name is the template's name, which its layers in Layers carry.
+	Name string
+	// Own is the template's template.yaml. Composed is the one its extends
+	// chain adds up to, nil when the chain could not be loaded: the checks
+	// that need the base template are then skipped.
+	Own, Composed *loader.Template
+	// Layers are those init renders, base first (only the template's own
+	// when Composed is nil).
+	Layers []renderer.Layer
+	// DefaultPlaceholder is the module placeholder of templates that do not
+	// set files.modulePlaceholder.
+	DefaultPlaceholder string
+}
+
+// Check runs every check on t but the schema's (see ValidateSchema).
+func Check(t Template) ([]Finding, error) {
+	in, err := renderer.Inspect(t.Layers)
+	if err != nil {
+		return nil, err
+	}
+	hooks := t.Own.Hooks
+	if t.Composed != nil {
+		hooks = t.Composed.Hooks
+	}
+	for i, h := range hooks.PreRender {
+		in.AddText(fmt.Sprintf("%s hooks.pre_render[%d]", templateYAML, i), h)
+	}
+	for i, h := range hooks.PostRender {
+		in.AddText(fmt.Sprintf("%s hooks.post_render[%d]", templateYAML, i), h)
+	}
+
+	var findings []Finding
+	for _, fe := range in.Errors {
+		msg := fe.Msg
+		if fe.Template != "" && fe.Template != t.Name {
+			msg += fmt.Sprintf(" (template %s)", fe.Template)
+		}
+		findings = append(findings, Finding{Severity: Error, Check: CheckParse, Path: fe.Path, Line: fe.Line, Col: fe.Col, Message: msg})
+	}
+	if t.Composed != nil {
+		findings = append(findings, checkCalls(in)...)
+		findings = append(findings, checkValues(in, t.Composed)...)
+	}
+	for i, f := range findings {
+		// Hooks are located in template.yaml, not in their own text
+		if hook, ok := strings.CutPrefix(f.Path, templateYAML+" "); ok {
+			findings[i].Path, findings[i].Line, findings[i].Col = templateYAML, 0, 0
+			findings[i].Message = hook + ": " + f.Message
+		}
+	}
+	findings = append(findings, checkPrompts(in, t.Own)...)
+	findings = append(findings, checkFeatures(in, t.Own)...)
+	findings = append(findings, checkFiles(in, t)...)
+	findings = append(findings, checkTypes(t.Own)...)
+	return append(findings, checkPlaceholder(in, t)...), nil
+}
+
+// checkCalls reports {{template}} calls of templates no partial or
+// {{define}} declares, which fail when the file is rendered.
+func checkCalls(in *renderer.Inspection) []Finding {
+	var findings []Finding
+	for _, c := range in.Calls {
+		if !in.Partials[c.Key] {
+			findings = append(findings, Finding{Severity: Error, Check: CheckPartials, Path: c.Path, Line: c.Line,
+				Message: fmt.Sprintf("{{template %q}}: no partial %s/%s.tmpl or {{define %q}}", c.Key, renderer.PartialsDir, c.Key, c.Key)})
+		}
+	}
+	return findings
+}
+
+// checkValues reports the values used that no prompt or default declares,
+// once per file.
+func checkValues(in *renderer.Inspection, t *loader.Template) []Finding {
+	declared := map[string]bool{"module": true, "projectName": true}
+	for _, p := range t.Prompts {
+		declared[p.Key] = true
+	}
+	for k := range t.Defaults {
+		declared[k] = true
+	}
+	seen := make(map[string]bool)
+	var findings []Finding
+	for _, r := range in.Refs {
+		if declared[r.Key] || seen[r.Path+"\x00"+r.Key] {
+			continue
+		}
+		seen[r.Path+"\x00"+r.Key] = true
+		findings = append(findings, Finding{Severity: Error, Check: CheckValues, Path: r.Path, Line: r.Line,
+			Message: fmt.Sprintf(".Values.%s is not declared in prompts or defaults", r.Key)})
+	}
+	return findings
+}
+
+// checkPrompts reports the prompts of t that no file, path or hook uses.
+func checkPrompts(in *renderer.Inspection, t *loader.Template) []Finding {
+	used := make(map[string]bool)
+	for _, r := range in.Refs {
+		used[r.Key] = true
+	}
+	var findings []Finding
+	for _, p := range t.Prompts {
+		if p.Key == "module" || p.Key == "projectName" || used[p.Key] {
+			continue
+		}
+		findings = append(findings, Finding{Severity: Warning, Check: CheckPrompts, Path: templateYAML,
+			Message: fmt.Sprintf("prompt %s is not used by any file, path or hook", p.Key)})
+	}
+	return findings
+}
+
+// checkFeatures reports the features of t that nothing in the template
+// stands for: no file or directory is named after them and no template
+// mentions them (e.g. {{if contains "metrics" ...}}).
+func checkFeatures(in *renderer.Inspection, t *loader.Template) []Finding {
+	var findings []Finding
+	for _, feature := range t.Features {
+		if in.Strings[feature] {
+			continue
+		}
+		found := false
+		for _, f := range in.Files {
+			if strings.Contains(normalize(path.Join(f.Dir, f.Path)), normalize(feature)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			findings = append(findings, Finding{Severity: Warning, Check: CheckFeatures, Path: templateYAML,
+				Message: fmt.Sprintf("feature %s has no files: no file or directory is named after it and no template mentions it", feature)})
+		}
+	}
+	return findings
+}
+
+// normalize lowercases s and drops - and _, so that "rate-limit" matches
+// ratelimit/ and rate_limit.go.
+func normalize(s string) string {
+	return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(s))
+}
+
+// checkFiles reports the files.* patterns of the template that match none
+// of its files, and, with files.include, the files no pattern includes.
+func checkFiles(in *renderer.Inspection, t Template) []Finding {
+	var own []renderer.InspectedFile
+	for _, f := range in.Files {
+		if f.Template == t.Name {
+			own = append(own, f)
+		}
+	}
+	files := t.Own.Files
+	var findings []Finding
+	for _, rule := range []struct {
+		key      string
+		patterns []string
+	}{
+		{"include", files.Include},
+		{"exclude", files.Exclude},
+		{"verbatim", files.Verbatim},
+		{"render", files.Render},
+	} {
+		for _, p := range rule.patterns {
+			matched := false
+			for _, f := range own {
+				if loader.MatchGlob(p, f.Path) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				findings = append(findings, Finding{Severity: Warning, Check: CheckFiles, Path: templateYAML,
+					Message: fmt.Sprintf("files.%s: %q matches no file", rule.key, p)})
+			}
+		}
+	}
+	if len(files.Include) == 0 {
+		return findings
+	}
+	for _, f := range own {
+		if !f.Generated && !matchAny(files.Exclude, f.Path) {
+			findings = append(findings, Finding{Severity: Warning, Check: CheckFiles, Path: path.Join(f.Dir, f.Path),
+				Message: "matches no files.include pattern, so it is never generated (use files.exclude if that is intended)"})
+		}
+	}
+	return findings
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if loader.MatchGlob(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTypes reports the types init does not know.
+func checkTypes(t *loader.Template) []Finding {
+	var findings []Finding
+	for _, typ := range t.Types {
+		if err := rules.ValidateType(typ); err != nil {
+			findings = append(findings, Finding{Severity: Error, Check: CheckTypes, Path: templateYAML, Message: err.Error()})
+		}
+	}
+	return findings
+}
+
+// checkPlaceholder reports where the module placeholder is not what the
+// template's files use:
+//   - files contain the default placeholder, which files.modulePlaceholder
+//     should then declare;
+//   - go.mod declares another module, which is not replaced;
+//   - a rendered file, or one whose extension is not a text one, contains
+//     the placeholder, which is only replaced in text files.
+func checkPlaceholder(in *renderer.Inspection, t Template) []Finding {
+	placeholder := t.Own.Files.ModulePlaceholder
+	if placeholder == "" {
+		placeholder = t.DefaultPlaceholder
+	}
+	layers := make(map[string]fs.FS)
+	for _, l := range t.Layers {
+		if l.Name == t.Name {
+			layers[l.Dir] = l.FS
+		}
+	}
+
+	var findings []Finding
+	var defaultUse *Finding
+	for _, f := range in.Files {
+		fsys, ok := layers[f.Dir]
+		if f.Template != t.Name || !ok || !f.Generated || f.Verbatim || f.Symlink {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, f.Path)
+		if err != nil || writer.IsBinary(data) {
+			continue
+		}
+		display := path.Join(f.Dir, f.Path)
+
+		if f.Path == "go.mod" && !f.Rendered {
+			if module, line := moduleLine(data); module != "" && module != placeholder {
+				findings = append(findings, Finding{Severity: Warning, Check: CheckPlaceholder, Path: display, Line: line,
+					Message: fmt.Sprintf("module %s is not the module placeholder %s and is not replaced; set files.modulePlaceholder: %s", module, placeholder, module)})
+			}
+		}
+
+		line := lineOf(data, placeholder)
+		if line == 0 {
+			continue
+		}
+		if t.Own.Files.ModulePlaceholder == "" && defaultUse == nil {
+			defaultUse = &Finding{Severity: Warning, Check: CheckPlaceholder, Path: display, Line: line,
+				Message: fmt.Sprintf("contains the default module placeholder %s, but files.modulePlaceholder is not set; declare it in template.yaml", placeholder)}
+		}
+		switch {
+		case f.Rendered:
+			findings = append(findings, Finding{Severity: Warning, Check: CheckPlaceholder, Path: display, Line: line,
+				Message: fmt.Sprintf("the module placeholder %s is not replaced in rendered files; use {{.Module}}", placeholder)})
+		case !f.Text:
+			findings = append(findings, Finding{Severity: Warning, Check: CheckPlaceholder, Path: display, Line: line,
+				Message: fmt.Sprintf("the module placeholder %s is not replaced in %s files; add the extension to files.textExtensions", placeholder, extOf(f.Path))})
+		}
+	}
+	if defaultUse != nil {
+		findings = append([]Finding{*defaultUse}, findings...)
+	}
+	return findings
+}
+
+// moduleLine returns the module go.mod declares and its line.
+func moduleLine(data []byte) (string, int) {
+	s := bufio.NewScanner(strings.NewReader(string(data)))
+	for n := 1; s.Scan(); n++ {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(s.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`), n
+		}
+	}
+	return "", 0
+}
+
+// lineOf returns the first line of data containing s, or 0.
+func lineOf(data []byte, s string) int {
+	i := strings.Index(string(data), s)
+	if i < 0 {
+		return 0
+	}
+	return strings.Count(string(data[:i]), "\n") + 1
+}
+
+func extOf(name string) string {
+	if ext := path.Ext(name); ext != "" {
+		return ext
+	}
+	return path.Base(name)
+}
+
+// Sort orders findings by path and line, errors of a location first.
+func Sort(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Path != b.Path {
+			// template.yaml first
+			if a.Path == templateYAML || b.Path == templateYAML {
+				return a.Path == templateYAML
+			}
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Severity == Error && b.Severity != Error
+	})
+}
@@ -0,0 +1,198 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cosmos-toolkit/cli/internal/loader"
+	"github.com/cosmos-toolkit/cli/internal/renderer"
+)
+
+const testPlaceholder = "github.com/your-org/your-app"
+
+// lintFiles lints a template made of files, which includes its
+// template.yaml, and returns its findings as "check path:line: message".
+func lintFiles(t *testing.T, files map[string]string) []string {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data), Mode: 0644}
+	}
+	tmpl, err := loader.LoadFromFS(fsys)
+	if err != nil {
+		t.Fatalf("LoadFromFS: %v", err)
+	}
+	engine, err := renderer.NewEngine(tmpl.Files)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	placeholder := tmpl.Files.ModulePlaceholder
+	if placeholder == "" {
+		placeholder = testPlaceholder
+	}
+	layer := renderer.Layer{FS: fsys, ModulePlaceholder: placeholder, Engine: engine, Rules: renderer.NewRules(tmpl.Files), Name: "test"}
+	findings, err := Check(Template{Name: "test", Own: tmpl, Composed: tmpl, Layers: []renderer.Layer{layer}, DefaultPlaceholder: testPlaceholder})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	Sort(findings)
+	out := make([]string, len(findings))
+	for i, f := range findings {
+		out[i] = f.Check + " " + f.Location() + ": " + f.Message
+	}
+	return out
+}
+
+// expectFindings fails unless got has one finding per want, each
+// containing its want.
+func expectFindings(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	ok := len(got) == len(want)
+	for i := 0; ok && i < len(want); i++ {
+		ok = strings.Contains(got[i], want[i])
+	}
+	if !ok {
+		t.Errorf("findings:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+const goodYAML = `name: test
+files:
+  engine: gotmpl
+  modulePlaceholder: github.com/your-org/your-app
+prompts:
+  - key: db
+    type: bool
+features:
+  - metrics
+`
+
+func TestCheckCleanTemplate(t *testing.T) {
+	got := lintFiles(t, map[string]string{
+		"template.yaml":       goodYAML,
+		"go.mod":              "module github.com/your-org/your-app\n\ngo 1.24\n",
+		"main.go.tmpl":        "package main\n\nimport _ \"{{.Module}}/internal/db\" // {{.Values.db}}\n",
+		"internal/metrics.go": "package internal\n",
+	})
+	expectFindings(t, got)
+}
+
+func TestCheckParseAndValues(t *testing.T) {
+	got := lintFiles(t, map[string]string{
+		"template.yaml":  goodYAML + "hooks:\n  post_render:\n    - echo {{.Values.hookValue}}\n",
+		"metrics.go":     "package main\n",
+		"broken.go.tmpl": "package main\n\n{{if .Values.db}}\n",
+		"main.go.tmpl":   "package main\n// {{.Values.db}} {{.Values.missing}}\n{{template \"header\" .}}\n// {{.Values.missing}}\n",
+	})
+	expectFindings(t, got,
+		`values template.yaml: hooks.post_render[0]: .Values.hookValue is not declared`,
+		`parse broken.go.tmpl:4: unexpected EOF`,
+		`values main.go.tmpl:2: .Values.missing is not declared in prompts or defaults`,
+		`partials main.go.tmpl:3: {{template "header"}}: no partial _partials/header.tmpl`,
+	)
+}
+
+func TestCheckUnusedPromptsAndFeatures(t *testing.T) {
+	got := lintFiles(t, map[string]string{
+		"template.yaml":        goodYAML + "  - rate-limit\n  - tracing\n",
+		"ratelimit/limiter.go": "package ratelimit\n",
+		"main.go.tmpl":         "package main\n{{$feature := \"metrics\"}}\n",
+	})
+	expectFindings(t, got,
+		`prompts template.yaml: prompt db is not used`,
+		`features template.yaml: feature tracing has no files`,
+	)
+}
+
+func TestCheckFilePatterns(t *testing.T) {
+	got := lintFiles(t, map[string]string{
+		"template.yaml": `name: test
+files:
+  engine: gotmpl
+  modulePlaceholder: github.com/your-org/your-app
+  include: ["cmd/**", "docs/*.md"]
+  exclude: ["cmd/legacy.go", "*.bak"]
+  verbatim: ["charts/**"]
+`,
+		"cmd/main.go":   "package main\n",
+		"cmd/legacy.go": "package main\n",
+		"internal/x.go": "package internal\n",
+	})
+	expectFindings(t, got,
+		`files template.yaml: files.include: "docs/*.md" matches no file`,
+		`files template.yaml: files.exclude: "*.bak" matches no file`,
+		`files template.yaml: files.verbatim: "charts/**" matches no file`,
+		`files internal/x.go: matches no files.include pattern`,
+	)
+}
+
+func TestCheckTypes(t *testing.T) {
+	got := lintFiles(t, map[string]string{
+		"template.yaml":   goodYAML + "types: [api, spaceship]\n",
+		"metrics.go.tmpl": "package main\n// {{.Values.db}}\n",
+	})
+	expectFindings(t, got, `types template.yaml: invalid type: spaceship`)
+}
+
+func TestCheckPlaceholder(t *testing.T) {
+	got := lintFiles(t, map[string]string{
+		"template.yaml":  "name: test\nfiles:\n  engine: gotmpl\n",
+		"go.mod":         "module github.com/acme/starter\n\ngo 1.24\n",
+		"main.go":        "package main\n\nimport _ \"github.com/your-org/your-app/internal\"\n",
+		"README.md.tmpl": "# {{.ProjectName}}\n\ngo install github.com/your-org/your-app@latest\n",
+		"api.proto":      "syntax = \"proto3\";\noption go_package = \"github.com/your-org/your-app/api\";\n",
+	})
+	expectFindings(t, got,
+		`placeholder README.md.tmpl:3: contains the default module placeholder github.com/your-org/your-app, but files.modulePlaceholder is not set`,
+		`placeholder README.md.tmpl:3: the module placeholder github.com/your-org/your-app is not replaced in rendered files; use {{.Module}}`,
+		`placeholder api.proto:2: the module placeholder github.com/your-org/your-app is not replaced in .proto files`,
+		`placeholder go.mod:1: module github.com/acme/starter is not the module placeholder`,
+	)
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{"valid", goodYAML, nil},
+		{"empty", "", []string{"template.yaml is empty"}},
+		{"syntax", "name: [test\n", []string{"yaml: "}},
+		{"misspelled key", "name: test\nprompt:\n  - key: db\n", []string{"prompt"}},
+		{"wrong type", "name: test\nfeatures: metrics\n", []string{"features"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := ValidateSchema([]byte(tt.yaml))
+			if len(findings) != len(tt.want) {
+				t.Fatalf("findings = %+v, want %d", findings, len(tt.want))
+			}
+			for i, f := range findings {
+				if f.Severity != Error || f.Path != templateYAML || !strings.Contains(f.Message, tt.want[i]) {
+					t.Errorf("finding = %+v, want an error about %q", f, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	findings := []Finding{
+		{Severity: Warning, Path: "b.go", Line: 2},
+		{Severity: Warning, Path: "a.go", Line: 5},
+		{Severity: Error, Path: "a.go", Line: 5},
+		{Severity: Warning, Path: templateYAML},
+		{Severity: Warning, Path: "a.go", Line: 1},
+	}
+	Sort(findings)
+	var got []string
+	for _, f := range findings {
+		got = append(got, string(f.Severity)+" "+f.Location())
+	}
+	want := "warning template.yaml, warning a.go:1, error a.go:5, warning a.go:5, warning b.go:2"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("Sort = %s, want %s", strings.Join(got, ", "), want)
+	}
+}
@@ -0,0 +1,252 @@
+package lint
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed template.schema.json
+var schemaJSON []byte
+
+// Schema returns the JSON Schema of template.yaml, e.g. for editors.
+func Schema() []byte {
+	return schemaJSON
+}
+
+// schema is the subset of JSON Schema template.schema.json uses.
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Defs                 map[string]*schema `json:"$defs"`
+	Type                 schemaTypes        `json:"type"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *additional        `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []string           `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+
+	pattern *regexp.Regexp
+}
+
+// schemaTypes is "type": one type or a list of them.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// additional is "additionalProperties": false, or the schema of the
+// properties not listed.
+type additional struct {
+	forbidden bool
+	schema    *schema
+}
+
+func (a *additional) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(data, &allowed); err == nil {
+		a.forbidden = !allowed
+		return nil
+	}
+	return json.Unmarshal(data, &a.schema)
+}
+
+var rootSchema = mustParseSchema(schemaJSON)
+
+func mustParseSchema(data []byte) *schema {
+	var s schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		panic(fmt.Sprintf("lint: invalid template.schema.json: %v", err))
+	}
+	return &s
+}
+
+// ValidateSchema validates template.yaml, whose content is data, against the
+// schema.
+func ValidateSchema(data []byte) []Finding {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []Finding{{Severity: Error, Check: CheckTemplateYAML, Path: templateYAML, Line: yamlErrorLine(err.Error()), Message: err.Error()}}
+	}
+	if len(doc.Content) == 0 {
+		return []Finding{{Severity: Error, Check: CheckSchema, Path: templateYAML, Message: "template.yaml is empty"}}
+	}
+	v := validator{root: rootSchema}
+	v.validate(rootSchema, doc.Content[0], "")
+	return v.findings
+}
+
+type validator struct {
+	root     *schema
+	findings []Finding
+}
+
+func (v *validator) fail(n *yaml.Node, at, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if at != "" {
+		msg = at + ": " + msg
+	}
+	v.findings = append(v.findings, Finding{Severity: Error, Check: CheckSchema, Path: templateYAML, Line: n.Line, Message: msg})
+}
+
+// validate checks the node n, at the path at of the document, against s.
+func (v *validator) validate(s *schema, n *yaml.Node, at string) {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/$defs/")
+		def, ok := v.root.Defs[name]
+		if !ok {
+			panic("lint: unknown schema reference " + s.Ref)
+		}
+		s = def
+	}
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	kind := nodeType(n)
+	if kind == "null" {
+		// An empty value is the zero value to the loader
+		return
+	}
+	if len(s.Type) > 0 && !typeAllowed(s.Type, kind) {
+		v.fail(n, at, "must be %s, not %s", strings.Join(s.Type, " or "), kind)
+		return
+	}
+
+	switch kind {
+	case "object":
+		present := make(map[string]bool)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i].Value, n.Content[i+1]
+			present[key] = true
+			if ps, ok := s.Properties[key]; ok {
+				v.validate(ps, value, join(at, key))
+				continue
+			}
+			switch a := s.AdditionalProperties; {
+			case a != nil && a.forbidden:
+				v.fail(n.Content[i], at, "unknown key %q%s", key, suggest(key, s.Properties))
+			case a != nil && a.schema != nil:
+				v.validate(a.schema, value, join(at, key))
+			}
+		}
+		for _, r := range s.Required {
+			if !present[r] {
+				v.fail(n, at, "%s is required", r)
+			}
+		}
+	case "array":
+		if s.MinItems != nil && len(n.Content) < *s.MinItems {
+			v.fail(n, at, "must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(n.Content) > *s.MaxItems {
+			v.fail(n, at, "must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range n.Content {
+				v.validate(s.Items, item, at+"["+strconv.Itoa(i)+"]")
+			}
+		}
+	default:
+		if len(s.Enum) > 0 && !contains(s.Enum, n.Value) {
+			v.fail(n, at, "%q is not one of %s", n.Value, strings.Join(s.Enum, ", "))
+		}
+		if s.Pattern != "" {
+			if s.pattern == nil {
+				s.pattern = regexp.MustCompile(s.Pattern)
+			}
+			if !s.pattern.MatchString(n.Value) {
+				v.fail(n, at, "%q does not match %s", n.Value, s.Pattern)
+			}
+		}
+	}
+}
+
+// nodeType is the JSON Schema type of n.
+func nodeType(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch n.ShortTag() {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	}
+	return "string"
+}
+
+func typeAllowed(types schemaTypes, kind string) bool {
+	for _, t := range types {
+		if t == kind || (t == "number" && kind == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func join(at, key string) string {
+	if at == "" {
+		return key
+	}
+	return at + "." + key
+}
+
+// suggest points to the known key an unknown key is probably a typo of.
+func suggest(key string, known map[string]*schema) string {
+	names := make([]string, 0, len(known))
+	for k := range known {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if strings.EqualFold(k, key) || strings.EqualFold(k, key+"s") || strings.EqualFold(k+"s", key) {
+			return fmt.Sprintf(" (did you mean %s?)", k)
+		}
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlErrorLine returns the line of a yaml.v3 syntax error ("yaml: line
+// 3: ..."), or 0.
+func yamlErrorLine(msg string) int {
+	rest, ok := strings.CutPrefix(msg, "yaml: line ")
+	if !ok {
+		return 0
+	}
+	i := strings.IndexByte(rest, ':')
+	if i < 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(rest[:i])
+	return n
+}
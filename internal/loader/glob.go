@@ -0,0 +1,71 @@
+package loader
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// MatchGlob reports whether the slash-separated path name matches pattern,
+// as in .gitignore: * and ? do not cross a /, ** matches any number of
+// directories, a pattern without / matches a file or directory name at
+// any depth, and a pattern that matches a directory matches everything
+// below it. "docs/fixtures/**", "*.png" and "docs" all match
+// "docs/fixtures/a.png" through one of these rules.
+func MatchGlob(pattern, name string) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+	segs := strings.Split(name, "/")
+	if !strings.Contains(pattern, "/") && pattern != "**" {
+		for _, s := range segs {
+			if ok, _ := path.Match(pattern, s); ok {
+				return true
+			}
+		}
+		return false
+	}
+	pat := strings.Split(pattern, "/")
+	for i := len(segs); i > 0; i-- {
+		if matchSegments(pat, segs[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+// validateGlob checks the syntax of a files.* pattern.
+func validateGlob(pattern string) error {
+	p := strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+	if p == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("pattern %q: invalid path element %q", pattern, seg)
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
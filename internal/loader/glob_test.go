@@ -0,0 +1,48 @@
+package loader
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		// A pattern without / matches a name at any depth
+		{"*.png", "a.png", true},
+		{"*.png", "docs/img/a.png", true},
+		{"*.png", "a.png.txt", false},
+		{"docs", "docs", true},
+		{"docs", "docs/guide.md", true},
+		{"docs", "src/docs/guide.md", true},
+		{"docs", "documents/guide.md", false},
+		{"?.go", "a.go", true},
+		{"?.go", "ab.go", false},
+
+		// A pattern with / is anchored at the template root
+		{"docs/*.md", "docs/guide.md", true},
+		{"docs/*.md", "docs/sub/guide.md", false},
+		{"docs/*.md", "src/docs/guide.md", false},
+		{"/docs", "docs/guide.md", true},
+		{"docs/", "docs/guide.md", true},
+		{"cmd/app", "cmd/app/main.go", true},
+		{"cmd/app", "cmd/application/main.go", false},
+
+		// ** matches any number of directories
+		{"**", "a/b/c.go", true},
+		{"fixtures/**", "fixtures/a.png", true},
+		{"fixtures/**", "fixtures/deep/a.png", true},
+		{"fixtures/**", "docs/fixtures/a.png", false},
+		{"**/fixtures", "docs/fixtures/a.png", true},
+		{"**/fixtures", "fixtures/a.png", true},
+		{"**/*.tmpl", "a/b/c.tmpl", true},
+		{"**/*.tmpl", "c.tmpl", true},
+		{"a/**/b.go", "a/b.go", true},
+		{"a/**/b.go", "a/x/y/b.go", true},
+		{"a/**/b.go", "x/a/b.go", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
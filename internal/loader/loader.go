@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -17,17 +19,75 @@ type Template struct {
 	Prompts  []Prompt          `yaml:"prompts"`
 	Features []string          `yaml:"features"`
 	Files    FileConfig        `yaml:"files"`
+	// Extends names the template this one builds on: a built-in type (api,
+	// worker, cli) or an external template. Its files are rendered first.
+	Extends string `yaml:"extends"`
+	// Overlays are directories of this template rendered, in order, on top
+	// of its other files.
+	Overlays []string `yaml:"overlays"`
+	Hooks    Hooks    `yaml:"hooks"`
+}
+
+// Hooks are shell commands run in the project directory before the files
+// are rendered and after. They are templates, rendered like file names.
+type Hooks struct {
+	PreRender  []string `yaml:"pre_render"`
+	PostRender []string `yaml:"post_render"`
 }
 
 type Prompt struct {
 	Key         string `yaml:"key"`
 	Description string `yaml:"description"`
 	Required    bool   `yaml:"required"`
+	// Type is the kind of value the prompt takes (string when not set).
+	Type string `yaml:"type"`
+	// Default is used when no answer is given.
+	Default any `yaml:"default"`
 }
 
+// Prompt types.
+const (
+	PromptString = "string"
+	PromptBool   = "bool"
+	PromptInt    = "int"
+	PromptList   = "list"
+)
+
+// Template engines (files.engine).
+const (
+	// EngineGoTemplate renders .tmpl files with text/template.
+	EngineGoTemplate = "gotmpl"
+	// EngineGoTemplateDelims is gotmpl with the delimiters of files.delims,
+	// for templates whose files contain {{ }} themselves (Helm charts,
+	// html/template files).
+	EngineGoTemplateDelims = "gotmpl-delims"
+	// EngineNone copies every file verbatim.
+	EngineNone = "none"
+)
+
 type FileConfig struct {
-	Engine            string `yaml:"engine"`
-	ModulePlaceholder string `yaml:"modulePlaceholder"` // e.g. "github.com/your-org/your-app" - replaced with user's module in all text files
+	Engine string `yaml:"engine"`
+	// Delims are the left and right delimiters of the gotmpl-delims engine,
+	// e.g. ["[[", "]]"].
+	Delims            []string `yaml:"delims"`
+	ModulePlaceholder string   `yaml:"modulePlaceholder"` // e.g. "github.com/your-org/your-app" - replaced with user's module in all text files
+
+	// The following are globs (see MatchGlob) on paths relative to the
+	// template (or overlay) directory.
+
+	// Include, when set, lists the only files that are generated.
+	Include []string `yaml:"include"`
+	// Exclude lists files that are not generated.
+	Exclude []string `yaml:"exclude"`
+	// Verbatim lists files copied as they are: no rendering, no module
+	// placeholder replacement, and the file name is kept (.tmpl included).
+	Verbatim []string `yaml:"verbatim"`
+	// Render lists files rendered by the engine even without the .tmpl
+	// suffix.
+	Render []string `yaml:"render"`
+	// TextExtensions adds extensions (e.g. ".proto") to those of text
+	// files, in which the module placeholder is replaced.
+	TextExtensions []string `yaml:"textExtensions"`
 }
 
 func LoadFromFS(fsys fs.FS) (*Template, error) {
@@ -66,13 +126,132 @@ func validateTemplate(t *Template) error {
 		return fmt.Errorf("template name is required")
 	}
 
-	if t.Files.Engine == "" {
+	// A template that extends another inherits its engine.
+	if t.Files.Engine == "" && t.Extends == "" {
 		return fmt.Errorf("files.engine is required")
 	}
+	if err := validateEngine(t.Files); err != nil {
+		return err
+	}
+	for _, p := range t.Prompts {
+		if p.Key == "" {
+			return fmt.Errorf("prompts: key is required")
+		}
+		switch p.Type {
+		case "", PromptString, PromptBool, PromptInt, PromptList:
+		default:
+			return fmt.Errorf("prompt %s: unknown type %q (supported: %s, %s, %s, %s)", p.Key, p.Type, PromptString, PromptBool, PromptInt, PromptList)
+		}
+	}
+	for _, rule := range []struct {
+		key      string
+		patterns []string
+	}{
+		{"include", t.Files.Include},
+		{"exclude", t.Files.Exclude},
+		{"verbatim", t.Files.Verbatim},
+		{"render", t.Files.Render},
+	} {
+		for _, p := range rule.patterns {
+			if err := validateGlob(p); err != nil {
+				return fmt.Errorf("files.%s: %w", rule.key, err)
+			}
+		}
+	}
+	for _, ext := range t.Files.TextExtensions {
+		if strings.Trim(ext, ".") == "" || strings.ContainsAny(ext, "/*?[") {
+			return fmt.Errorf("files.textExtensions: invalid extension %q", ext)
+		}
+	}
+
+	for _, o := range t.Overlays {
+		clean := path.Clean(o)
+		if !fs.ValidPath(clean) || clean == "." {
+			return fmt.Errorf("overlay %q must be a directory inside the template", o)
+		}
+	}
 
 	return nil
 }
 
+func validateEngine(f FileConfig) error {
+	switch f.Engine {
+	case EngineGoTemplateDelims:
+		if len(f.Delims) != 2 || f.Delims[0] == "" || f.Delims[1] == "" {
+			return fmt.Errorf("files.delims must be a left and a right delimiter (e.g. [\"[[\", \"]]\"]) with engine %s", f.Engine)
+		}
+		return nil
+	case EngineGoTemplate, EngineNone, "":
+	default:
+		return fmt.Errorf("unknown files.engine %q (supported: %s, %s, %s)", f.Engine, EngineGoTemplate, EngineGoTemplateDelims, EngineNone)
+	}
+	if len(f.Delims) > 0 {
+		return fmt.Errorf("files.delims requires engine %s", EngineGoTemplateDelims)
+	}
+	return nil
+}
+
+// Inherit returns the template.yaml of child extending base: child's name,
+// version and files settings win, types fall back to base's, defaults and
+// prompts are merged by key (child wins), features are combined and hooks
+// run base first.
+func Inherit(base, child *Template) *Template {
+	t := *child
+	if len(t.Types) == 0 {
+		t.Types = base.Types
+	}
+	if t.Files.Engine == "" {
+		t.Files.Engine = base.Files.Engine
+		t.Files.Delims = base.Files.Delims
+	}
+
+	t.Defaults = make(map[string]string, len(base.Defaults)+len(child.Defaults))
+	for k, v := range base.Defaults {
+		t.Defaults[k] = v
+	}
+	for k, v := range child.Defaults {
+		t.Defaults[k] = v
+	}
+
+	t.Prompts = nil
+	overridden := make(map[string]Prompt, len(child.Prompts))
+	for _, p := range child.Prompts {
+		overridden[p.Key] = p
+	}
+	for _, p := range base.Prompts {
+		if o, ok := overridden[p.Key]; ok {
+			p = o
+			delete(overridden, p.Key)
+		}
+		t.Prompts = append(t.Prompts, p)
+	}
+	for _, p := range child.Prompts {
+		if _, ok := overridden[p.Key]; ok {
+			t.Prompts = append(t.Prompts, p)
+		}
+	}
+
+	t.Hooks.PreRender = append(append([]string(nil), base.Hooks.PreRender...), child.Hooks.PreRender...)
+	t.Hooks.PostRender = append(append([]string(nil), base.Hooks.PostRender...), child.Hooks.PostRender...)
+
+	t.Features = append([]string(nil), base.Features...)
+	for _, f := range child.Features {
+		if !contains(t.Features, f) {
+			t.Features = append(t.Features, f)
+		}
+	}
+	return &t
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *Template) SupportsType(typeName string) bool {
 	if len(t.Types) == 0 {
 		return true // No types specified means supports all
@@ -4,19 +4,28 @@ package pkginstall
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
-	"github.com/cosmos-toolkit/cli/internal/github"
+	"github.com/cosmos-toolkit/cli/internal/registry"
 	"github.com/cosmos-toolkit/cli/internal/resolver"
+	"github.com/cosmos-toolkit/cli/internal/writer"
 	"gopkg.in/yaml.v3"
 )
 
 const pkgsModule = "github.com/cosmos-toolkit/pkgs"
 
+// Erros sentinela para que o chamador classifique falhas com errors.Is.
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExists   = errors.New("already exists")
+)
+
 // Manifest descreve os pacotes e suas dependências.
 type Manifest struct {
 	Packages map[string]PackageMeta `yaml:"packages"`
@@ -32,13 +41,27 @@ type PackageMeta struct {
 type InstallOpts struct {
 	// Force overwrites existing pkg/<name> (and copy_deps) after removing them.
 	Force bool
+	// Output receives go get / go mod tidy output. Defaults to os.Stdout.
+	Output io.Writer
+
+	// goEnv is appended to the environment of go subprocesses.
+	goEnv []string
+}
+
+// fetchManifest reads manifest.yaml from the packages registry, or from the local packages
+// cache in offline mode.
+func fetchManifest() ([]byte, error) {
+	if resolver.IsOffline() {
+		return resolver.CachedPackagesManifest()
+	}
+	return registry.GetPackagesManifest()
 }
 
 // Install copia o pacote name e seus copy_deps para pkg/ no cwd, reescreve
 // imports para o module do projeto e executa go get para go_get.
 // If opts.Force is true and pkg/<name> (or any copy_dep) already exists, it is removed first.
 func Install(name, cwd string, opts InstallOpts) error {
-	manifestData, err := github.GetPackagesManifest()
+	manifestData, err := fetchManifest()
 	if err != nil {
 		return fmt.Errorf("failed to fetch manifest: %w", err)
 	}
@@ -50,7 +73,11 @@ func Install(name, cwd string, opts InstallOpts) error {
 
 	meta, ok := manifest.Packages[name]
 	if !ok {
-		return fmt.Errorf("package %q not found in manifest", name)
+		return fmt.Errorf("package %q %w in manifest", name, ErrNotFound)
+	}
+	if resolver.IsOffline() {
+		// Offline: go get / go mod tidy may only use the local module cache.
+		opts.goEnv = append(opts.goEnv, "GOPROXY=off")
 	}
 
 	repoPath, err := resolver.ResolvePackagesRepo()
@@ -82,31 +109,38 @@ func Install(name, cwd string, opts InstallOpts) error {
 		src := filepath.Join(srcPkg, n)
 		dst := filepath.Join(dstPkg, n)
 		if _, err := os.Stat(src); err != nil {
-			return fmt.Errorf("package %q not found in repo: %w", n, err)
+			return fmt.Errorf("package %q %w in repo: %w", n, ErrNotFound, err)
 		}
 		if opts.Force {
 			if err := os.RemoveAll(dst); err != nil {
 				return fmt.Errorf("failed to remove existing %q: %w", n, err)
 			}
 		} else if _, err := os.Stat(dst); err == nil {
-			return fmt.Errorf("package %q already exists in pkg/%s; use --force to overwrite", n, n)
+			return fmt.Errorf("package %q %w in pkg/%s; use --force to overwrite", n, ErrExists, n)
 		}
 		if err := copyDir(src, dst); err != nil {
 			return fmt.Errorf("failed to copy %q: %w", n, err)
 		}
 	}
 
+	resolver.RecordPackagesUsed(ordered...)
+
 	if err := rewriteImportsInDir(dstPkg, pkgsModule, modulePath); err != nil {
 		return fmt.Errorf("failed to rewrite imports: %w", err)
 	}
 
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
 	for _, imp := range meta.GoGet {
-		if err := runGoGet(cwd, imp); err != nil {
+		if err := runGoGet(cwd, imp, out, opts.goEnv); err != nil {
 			return fmt.Errorf("go get %s: %w", imp, err)
 		}
 	}
 
-	if err := runGoModTidy(cwd); err != nil {
+	if err := runGoModTidy(cwd, out, opts.goEnv); err != nil {
 		return fmt.Errorf("go mod tidy: %w", err)
 	}
 
@@ -129,6 +163,8 @@ func readModulePath(goModPath string) (string, error) {
 	return "", fmt.Errorf("module directive not found in go.mod")
 }
 
+// copyDir copies src to dst with the files' permissions. Symlinks are
+// recreated when they point inside src, and rejected otherwise.
 func copyDir(src, dst string) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -139,30 +175,39 @@ func copyDir(src, dst string) error {
 			return err
 		}
 		target := filepath.Join(dst, rel)
-		if info.IsDir() {
-			return os.MkdirAll(target, info.Mode())
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if !writer.LinkInside(filepath.ToSlash(rel), link) {
+				return fmt.Errorf("%s: symlink to %s points outside the package", filepath.ToSlash(rel), link)
+			}
+			return writer.WriteSymlink(target, link)
+		case !info.Mode().IsRegular():
+			return fmt.Errorf("%s: unsupported file type %s", filepath.ToSlash(rel), info.Mode().Type())
 		}
 		data, err := os.ReadFile(path)
 		if err != nil {
 			return err
 		}
-		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-			return err
-		}
-		return os.WriteFile(target, data, info.Mode())
+		return writer.WriteFile(target, data, info.Mode().Perm())
 	})
 }
 
 func rewriteImportsInDir(dir, fromModule, toModule string) error {
 	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
+		if err != nil || !info.Mode().IsRegular() {
 			return err
 		}
 		if filepath.Ext(path) != ".go" {
 			return nil
 		}
 		data, err := os.ReadFile(path)
-		if err != nil {
+		if err != nil || writer.IsBinary(data) {
 			return err
 		}
 		newData := strings.ReplaceAll(string(data), fromModule, toModule)
@@ -173,18 +218,20 @@ func rewriteImportsInDir(dir, fromModule, toModule string) error {
 	})
 }
 
-func runGoGet(cwd, pkg string) error {
+func runGoGet(cwd, pkg string, out io.Writer, env []string) error {
 	cmd := exec.Command("go", "get", pkg)
 	cmd.Dir = cwd
-	cmd.Stdout = os.Stdout
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = out
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
-func runGoModTidy(cwd string) error {
+func runGoModTidy(cwd string, out io.Writer, env []string) error {
 	cmd := exec.Command("go", "mod", "tidy")
 	cmd.Dir = cwd
-	cmd.Stdout = os.Stdout
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = out
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Git reads a repository from its local git checkout, for servers without
+// a supported API. Listing needs the checkout to exist, so the first use
+// clones it (without any template or package checked out).
+type Git struct {
+	url, ref string
+	checkout func() (string, error)
+}
+
+// ListDirs lists the tree of dir at HEAD; it works on sparse, blobless
+// clones because tree objects are always fetched.
+func (g *Git) ListDirs(dir string) ([]string, error) {
+	out, err := g.git("ls-tree", "-d", "--name-only", "HEAD:"+dir)
+	if err != nil {
+		if strings.Contains(err.Error(), "not a tree object") {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	dirs := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line != "" {
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs, nil
+}
+
+// GetFile returns the content of path at HEAD (fetching the blob if needed).
+func (g *Git) GetFile(path string) ([]byte, error) {
+	out, err := g.git("show", "HEAD:"+path)
+	if err != nil {
+		if strings.Contains(err.Error(), "does not exist") || strings.Contains(err.Error(), "exists on disk, but not in") {
+			return nil, fmt.Errorf("%s %w", path, ErrNotFound)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (g *Git) git(args ...string) ([]byte, error) {
+	if g.checkout == nil {
+		return nil, fmt.Errorf("registry %s: no local checkout available", g.url)
+	}
+	dir, err := g.checkout()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	// Credentials come from git itself (helpers, netrc, ssh keys); it must
+	// not prompt for them.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if os.Getenv("GIT_SSH_COMMAND") == "" && os.Getenv("GIT_SSH") == "" {
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, Redact(strings.TrimSpace(stderr.String())))
+	}
+	return out, nil
+}
+
+// Link returns the clone URL (without any password in it): plain git
+// servers have no known web UI.
+func (g *Git) Link(path string) string {
+	return Redact(g.url)
+}
+
+func (g *Git) CloneURL() string {
+	return g.url
+}
@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Gitea reads a repository through the Gitea API (v1), which Forgejo
+// implements too.
+type Gitea struct {
+	web, api, repo, ref string
+	ssh                 bool
+	client              *client
+	*tokenAuth
+}
+
+func newGitea(r Registry) *Gitea {
+	g := &Gitea{web: r.URL, api: r.API, repo: r.Repo, ref: r.Ref, ssh: r.Protocol == ProtocolSSH}
+	if g.api == "" {
+		g.api = g.web + "/api/v1"
+	}
+	g.tokenAuth = newTokenAuth(r, g.web, giteaTokenVars, false)
+	g.tokenAuth.gitUser = "oauth2"
+	if !g.ssh {
+		g.tokenAuth.cloneURL = g.CloneURL()
+	}
+	h := http.Header{}
+	h.Set("Accept", "application/json")
+	g.client = &client{
+		service:       "Gitea",
+		header:        h,
+		creds:         g.creds,
+		authorize:     func(h http.Header, token string) { h.Set("Authorization", "token "+token) },
+		anonymousHint: "set GITEA_TOKEN to authenticate",
+	}
+	return g
+}
+
+// giteaTokenVars returns GITEA_TOKEN for the host GITEA_HOST names, or for
+// any host when it is unset: Gitea has no public instance the token would
+// be meant for by default.
+func giteaTokenVars(host string) []string {
+	if h := hostFromEnv("GITEA_HOST", ""); h != "" && h != host {
+		return nil
+	}
+	return []string{"GITEA_TOKEN"}
+}
+
+func (g *Gitea) refQuery(sep string) string {
+	if g.ref == "" {
+		return ""
+	}
+	return sep + "ref=" + url.QueryEscape(g.ref)
+}
+
+// ListDirs lists dir with the contents API.
+func (g *Gitea) ListDirs(dir string) ([]string, error) {
+	u := fmt.Sprintf("%s/repos/%s/contents/%s%s", g.api, g.repo, dir, g.refQuery("?"))
+	resp, err := g.client.get(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch: %w", err)
+	}
+	dirs := []string{}
+	if resp.Status == http.StatusNotFound {
+		return dirs, nil
+	}
+	if resp.Status != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.Status)
+	}
+
+	var items []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(resp.Body, &items); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	for _, item := range items {
+		if item.Type == "dir" {
+			dirs = append(dirs, item.Name)
+		}
+	}
+	return dirs, nil
+}
+
+// GetFile returns the raw content of a file.
+func (g *Gitea) GetFile(path string) ([]byte, error) {
+	u := fmt.Sprintf("%s/repos/%s/raw/%s%s", g.api, g.repo, path, g.refQuery("?"))
+	resp, err := g.client.get(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file: %w", err)
+	}
+	if resp.Status == http.StatusNotFound {
+		return nil, fmt.Errorf("%s %w", path, ErrNotFound)
+	}
+	if resp.Status != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d for %s", resp.Status, path)
+	}
+	return resp.Body, nil
+}
+
+// Tarball downloads the repository at ref as .tar.gz.
+func (g *Gitea) Tarball() (io.ReadCloser, error) {
+	ref := g.ref
+	if ref == "" {
+		// The archive endpoint needs a ref; HEAD is not accepted.
+		var repo struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		resp, err := g.client.get(fmt.Sprintf("%s/repos/%s", g.api, g.repo))
+		if err != nil {
+			return nil, err
+		}
+		if resp.Status != http.StatusOK || json.Unmarshal(resp.Body, &repo) != nil || repo.DefaultBranch == "" {
+			return nil, fmt.Errorf("failed to find the default branch of %s (status %d)", g.repo, resp.Status)
+		}
+		ref = repo.DefaultBranch
+	}
+	return g.client.download(fmt.Sprintf("%s/repos/%s/archive/%s.tar.gz", g.api, g.repo, url.PathEscape(ref)))
+}
+
+func (g *Gitea) Link(path string) string {
+	return fmt.Sprintf("%s/%s/src/branch/%s/%s", g.web, g.repo, refOrHEAD(g.ref), path)
+}
+
+func (g *Gitea) CloneURL() string {
+	if g.ssh {
+		return sshCloneURL(g.web, g.repo)
+	}
+	return g.web + "/" + g.repo + ".git"
+}
@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	githubURL = "https://github.com"
+	githubAPI = "https://api.github.com"
+)
+
+// GitHub reads a repository through the GitHub REST API. GitHub Enterprise
+// Server is supported by setting URL (its API is at <url>/api/v3).
+type GitHub struct {
+	web, api, repo, ref string
+	ssh                 bool
+	client              *client
+	*tokenAuth
+}
+
+func newGitHub(r Registry) *GitHub {
+	g := &GitHub{web: r.URL, api: r.API, repo: r.Repo, ref: r.Ref, ssh: r.Protocol == ProtocolSSH}
+	if g.web == "" {
+		g.web = githubURL
+	}
+	if g.api == "" {
+		g.api = githubAPI
+		if g.web != githubURL {
+			g.api = g.web + "/api/v3"
+		}
+	}
+	g.tokenAuth = newTokenAuth(r, g.web, githubTokenVars, true)
+	g.tokenAuth.gitUser = "x-access-token"
+	if !g.ssh {
+		g.tokenAuth.cloneURL = g.CloneURL()
+	}
+	h := http.Header{}
+	h.Set("Accept", "application/vnd.github.v3+json")
+	g.client = &client{
+		service:       "GitHub",
+		header:        h,
+		creds:         g.creds,
+		authorize:     setBearer,
+		anonymousHint: "anonymous requests are limited to 60 per hour, set GITHUB_TOKEN (a token without scopes is enough) to raise it to 5000",
+	}
+	if hostOf(g.web) != githubHost {
+		g.client.anonymousHint = "set GH_ENTERPRISE_TOKEN to authenticate"
+	}
+	return g
+}
+
+// githubHost is the host GITHUB_TOKEN and GH_TOKEN are meant for.
+const githubHost = "github.com"
+
+// githubTokenVars returns the environment variables whose token may be sent
+// to host, as the gh CLI reads them: GITHUB_TOKEN and GH_TOKEN for
+// github.com only, GH_ENTERPRISE_TOKEN and GITHUB_ENTERPRISE_TOKEN for a
+// GitHub Enterprise Server, the one GH_HOST names when it is set.
+func githubTokenVars(host string) []string {
+	if host == githubHost {
+		return []string{"GITHUB_TOKEN", "GH_TOKEN"}
+	}
+	if h := hostFromEnv("GH_HOST", ""); h != "" && h != githubHost && h != host {
+		return nil
+	}
+	return []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+}
+
+type githubItem struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (g *GitHub) contentsURL(path string) string {
+	u := fmt.Sprintf("%s/repos/%s/contents/%s", g.api, g.repo, path)
+	if g.ref != "" {
+		return u + "?ref=" + url.QueryEscape(g.ref)
+	}
+	return u
+}
+
+// ListDirs lists dir with the contents API, following pagination.
+func (g *GitHub) ListDirs(dir string) ([]string, error) {
+	dirs := []string{}
+	for next := withQuery(g.contentsURL(dir), "per_page=100"); next != ""; {
+		resp, err := g.client.get(next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch: %w", err)
+		}
+		if resp.Status == http.StatusNotFound {
+			return dirs, nil
+		}
+		if resp.Status != http.StatusOK {
+			return nil, fmt.Errorf("API returned status %d", resp.Status)
+		}
+
+		var items []githubItem
+		if err := json.Unmarshal(resp.Body, &items); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		for _, item := range items {
+			if item.Type == "dir" && item.Name != "." && item.Name != ".." {
+				dirs = append(dirs, item.Name)
+			}
+		}
+		next = resp.Next
+	}
+	return dirs, nil
+}
+
+// GetFile returns a file from the contents API (base64 decoded).
+func (g *GitHub) GetFile(path string) ([]byte, error) {
+	resp, err := g.client.get(g.contentsURL(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file: %w", err)
+	}
+	if resp.Status == http.StatusNotFound {
+		return nil, fmt.Errorf("%s %w", path, ErrNotFound)
+	}
+	if resp.Status != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d for %s", resp.Status, path)
+	}
+
+	var fc struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	if err := json.Unmarshal(resp.Body, &fc); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if fc.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(fc.Content)
+	}
+	return []byte(fc.Content), nil
+}
+
+// Tarball downloads the repository at ref as .tar.gz.
+func (g *GitHub) Tarball() (io.ReadCloser, error) {
+	u := fmt.Sprintf("%s/repos/%s/tarball", g.api, g.repo)
+	if g.ref != "" {
+		u += "/" + url.PathEscape(g.ref)
+	}
+	return g.client.download(u)
+}
+
+func (g *GitHub) Link(path string) string {
+	return fmt.Sprintf("%s/%s/tree/%s/%s", g.web, g.repo, refOrHEAD(g.ref), path)
+}
+
+func (g *GitHub) CloneURL() string {
+	if g.ssh {
+		return sshCloneURL(g.web, g.repo)
+	}
+	return g.web + "/" + g.repo
+}
+
+// withQuery appends a query parameter to u.
+func withQuery(u, param string) string {
+	if strings.Contains(u, "?") {
+		return u + "&" + param
+	}
+	return u + "?" + param
+}
@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// GitLab reads a repository through the GitLab REST API (v4), on gitlab.com
+// or a self-managed instance.
+type GitLab struct {
+	web, api, repo, ref string
+	ssh                 bool
+	client              *client
+	*tokenAuth
+}
+
+func newGitLab(r Registry) *GitLab {
+	g := &GitLab{web: r.URL, api: r.API, repo: r.Repo, ref: r.Ref, ssh: r.Protocol == ProtocolSSH}
+	if g.api == "" {
+		g.api = g.web + "/api/v4"
+	}
+	g.tokenAuth = newTokenAuth(r, g.web, gitlabTokenVars, false)
+	g.tokenAuth.gitUser = "oauth2"
+	if !g.ssh {
+		g.tokenAuth.cloneURL = g.CloneURL()
+	}
+	g.client = &client{
+		service:       "GitLab",
+		header:        http.Header{},
+		creds:         g.creds,
+		authorize:     func(h http.Header, token string) { h.Set("PRIVATE-TOKEN", token) },
+		anonymousHint: "set GITLAB_TOKEN to authenticate",
+	}
+	if hostOf(g.web) != hostFromEnv("GITLAB_HOST", gitlabHost) {
+		g.client.anonymousHint = "set GITLAB_HOST=" + hostOf(g.web) + " and GITLAB_TOKEN, or the registry's token in the config file, to authenticate"
+	}
+	return g
+}
+
+// gitlabHost is the host GITLAB_TOKEN is meant for unless GITLAB_HOST says
+// otherwise.
+const gitlabHost = "gitlab.com"
+
+// gitlabTokenVars returns GITLAB_TOKEN for the host it is meant for, as the
+// glab CLI reads it: GITLAB_HOST when set, else gitlab.com.
+func gitlabTokenVars(host string) []string {
+	if host == hostFromEnv("GITLAB_HOST", gitlabHost) {
+		return []string{"GITLAB_TOKEN"}
+	}
+	return nil
+}
+
+// projectURL returns the API URL of the project; its path is URL-encoded
+// as the project id.
+func (g *GitLab) projectURL() string {
+	return g.api + "/projects/" + url.PathEscape(g.repo)
+}
+
+func (g *GitLab) refQuery() string {
+	if g.ref == "" {
+		return ""
+	}
+	return "&ref=" + url.QueryEscape(g.ref)
+}
+
+// ListDirs lists dir with the repository tree API, following pagination.
+func (g *GitLab) ListDirs(dir string) ([]string, error) {
+	dirs := []string{}
+	next := fmt.Sprintf("%s/repository/tree?path=%s&per_page=100%s", g.projectURL(), url.QueryEscape(dir), g.refQuery())
+	for next != "" {
+		resp, err := g.client.get(next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch: %w", err)
+		}
+		if resp.Status == http.StatusNotFound {
+			return dirs, nil
+		}
+		if resp.Status != http.StatusOK {
+			return nil, fmt.Errorf("API returned status %d", resp.Status)
+		}
+
+		var items []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(resp.Body, &items); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		for _, item := range items {
+			if item.Type == "tree" {
+				dirs = append(dirs, item.Name)
+			}
+		}
+		next = resp.Next
+	}
+	return dirs, nil
+}
+
+// GetFile returns the raw content of a file.
+func (g *GitLab) GetFile(path string) ([]byte, error) {
+	u := fmt.Sprintf("%s/repository/files/%s/raw?ref=%s", g.projectURL(), url.PathEscape(path), url.QueryEscape(refOrHEAD(g.ref)))
+	resp, err := g.client.get(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file: %w", err)
+	}
+	if resp.Status == http.StatusNotFound {
+		return nil, fmt.Errorf("%s %w", path, ErrNotFound)
+	}
+	if resp.Status != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d for %s", resp.Status, path)
+	}
+	return resp.Body, nil
+}
+
+// Tarball downloads the repository at ref as .tar.gz.
+func (g *GitLab) Tarball() (io.ReadCloser, error) {
+	u := g.projectURL() + "/repository/archive.tar.gz"
+	if g.ref != "" {
+		u += "?sha=" + url.QueryEscape(g.ref)
+	}
+	return g.client.download(u)
+}
+
+func (g *GitLab) Link(path string) string {
+	return fmt.Sprintf("%s/%s/-/tree/%s/%s", g.web, g.repo, refOrHEAD(g.ref), path)
+}
+
+func (g *GitLab) CloneURL() string {
+	if g.ssh {
+		return sshCloneURL(g.web, g.repo)
+	}
+	return g.web + "/" + g.repo + ".git"
+}
@@ -0,0 +1,339 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTimeout is the timeout of one API request attempt (avoids hanging).
+	defaultTimeout = 30 * time.Second
+	// archiveTimeout bounds a whole archive download, body included.
+	archiveTimeout = 5 * time.Minute
+	// maxAttempts bounds retries of 5xx and rate-limited responses.
+	maxAttempts = 4
+	// baseBackoff is the first retry delay; it doubles on every attempt.
+	baseBackoff = time.Second
+	// maxRetryWait is the longest cosmos waits for a rate limit to reset
+	// before giving up with a RateLimitError.
+	maxRetryWait = time.Minute
+)
+
+// ErrRateLimited is wrapped by RateLimitError.
+var ErrRateLimited = errors.New("API rate limit exceeded")
+
+// ErrNotFound is returned when the repository, ref or file does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrUnauthorized is returned when the server rejects the credentials, or
+// requires some.
+var ErrUnauthorized = errors.New("authentication failed")
+
+// RateLimitError is returned when a server keeps refusing requests because
+// of its primary or secondary rate limit. Hint tells how to raise the limit
+// when the requests were anonymous.
+type RateLimitError struct {
+	Service string
+	Reset   time.Time
+	Hint    string
+}
+
+func (e *RateLimitError) Error() string {
+	msg := e.Service + " " + ErrRateLimited.Error()
+	if !e.Reset.IsZero() {
+		msg += fmt.Sprintf(" (resets at %s)", e.Reset.Format(time.Kitchen))
+	}
+	if e.Hint != "" {
+		msg += "; " + e.Hint
+	}
+	return msg
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
+
+// httpClient is used for all API calls (timeout per attempt; see get for
+// retries).
+var httpClient = &http.Client{Timeout: defaultTimeout}
+
+// sleep waits between retries; tests replace it.
+var sleep = time.Sleep
+
+// httpCacheDir holds ETags and bodies of previous responses; "" disables
+// conditional requests.
+var httpCacheDir string
+
+// SetCacheDir sets the directory of the on-disk HTTP cache used for
+// conditional requests (If-None-Match). On GitHub a 304 reply does not
+// count against the rate limit.
+func SetCacheDir(dir string) {
+	httpCacheDir = dir
+}
+
+// client issues API requests with fixed headers and, when a credential is
+// found, the token in the form authorize sets.
+type client struct {
+	service   string
+	header    http.Header
+	creds     *credentials
+	authorize func(h http.Header, token string)
+	// anonymousHint is the RateLimitError hint used without credentials.
+	anonymousHint string
+}
+
+// response is a completed API response.
+type response struct {
+	Status int
+	Body   []byte
+	// Next is the URL of the next page (Link rel="next"), if any.
+	Next string
+}
+
+// cachedResponse is the on-disk form of a 200 response with an ETag.
+type cachedResponse struct {
+	ETag string `json:"etag"`
+	Next string `json:"next,omitempty"`
+	Body []byte `json:"body"`
+}
+
+func (c *client) token() string {
+	if c.creds == nil {
+		return ""
+	}
+	return c.creds.get().Token
+}
+
+func (c *client) authenticated() bool {
+	return c.token() != ""
+}
+
+// get fetches url, revalidating a cached copy with its ETag and retrying
+// 5xx and rate-limited responses with backoff. Other statuses are returned
+// to the caller as is.
+func (c *client) get(url string) (*response, error) {
+	key := c.cacheKey(url)
+	cached := readCached(key)
+	for attempt := 1; ; attempt++ {
+		resp, header, err := c.fetch(url, cached)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Status == http.StatusNotModified && cached != nil {
+			return &response{Status: http.StatusOK, Body: cached.Body, Next: cached.Next}, nil
+		}
+		if resp.Status == http.StatusOK {
+			if etag := header.Get("ETag"); etag != "" {
+				writeCached(key, &cachedResponse{ETag: etag, Next: resp.Next, Body: resp.Body})
+			}
+			return resp, nil
+		}
+
+		if resp.Status == http.StatusUnauthorized {
+			return nil, c.unauthorized()
+		}
+
+		wait, retry, rlErr := c.retryDelay(resp, header, attempt)
+		if !retry || attempt == maxAttempts {
+			if rlErr != nil {
+				return nil, rlErr
+			}
+			return resp, nil
+		}
+		sleep(wait)
+	}
+}
+
+func (c *client) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	if token := c.token(); token != "" {
+		c.authorize(req.Header, token)
+	}
+	return req, nil
+}
+
+func (c *client) fetch(url string, cached *cachedResponse) (*response, http.Header, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, url)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cached != nil {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &response{Status: resp.StatusCode, Body: body, Next: nextLink(resp.Header.Get("Link"))}, resp.Header, nil
+}
+
+// download streams url (an archive) with a longer timeout than API calls.
+// The caller closes the returned body.
+func (c *client) download(url string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	req, err := c.newRequest(ctx, url)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// Redirects (e.g. to codeload.github.com) are followed.
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to download archive: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("archive %w", ErrNotFound)
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, c.unauthorized()
+		}
+		return nil, fmt.Errorf("failed to download archive: API returned status %d", resp.StatusCode)
+	}
+	return &cancelBody{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// cancelBody releases the request context when the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// unauthorized describes a 401 reply: the token was rejected (naming where
+// it came from, never its value) or none was found.
+func (c *client) unauthorized() error {
+	if c.authenticated() {
+		return fmt.Errorf("%s %w: the token from %s was rejected (expired or revoked?)", c.service, ErrUnauthorized, c.creds.get().Source)
+	}
+	return fmt.Errorf("%s %w: no token found; %s", c.service, ErrUnauthorized, c.anonymousHint)
+}
+
+// retryDelay decides whether a failed response is retried and after how
+// long. Rate limits honor Retry-After, then X-RateLimit-Reset; a reset too
+// far away yields a RateLimitError instead of a retry.
+func (c *client) retryDelay(resp *response, header http.Header, attempt int) (wait time.Duration, retry bool, rlErr error) {
+	backoff := baseBackoff<<(attempt-1) + time.Duration(rand.Int63n(int64(baseBackoff)))
+
+	switch {
+	case resp.Status >= 500:
+		return backoff, true, nil
+	case resp.Status != http.StatusForbidden && resp.Status != http.StatusTooManyRequests:
+		return 0, false, nil
+	}
+
+	hint := ""
+	if !c.authenticated() {
+		hint = c.anonymousHint
+	}
+	if secs, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		wait = time.Duration(secs) * time.Second
+		return wait, wait <= maxRetryWait, &RateLimitError{Service: c.service, Reset: time.Now().Add(wait), Hint: hint}
+	}
+	if header.Get("X-RateLimit-Remaining") == "0" {
+		rlErr = &RateLimitError{Service: c.service, Hint: hint}
+		if unix, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			reset := time.Unix(unix, 0)
+			wait = time.Until(reset) + time.Second
+			return wait, wait <= maxRetryWait, &RateLimitError{Service: c.service, Reset: reset, Hint: hint}
+		}
+		return 0, false, rlErr
+	}
+	if resp.Status == http.StatusTooManyRequests || strings.Contains(strings.ToLower(string(resp.Body)), "secondary rate limit") {
+		return backoff, true, &RateLimitError{Service: c.service, Hint: hint}
+	}
+	// A plain 403 (e.g. a token without access) is not retried.
+	return 0, false, nil
+}
+
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="next"`)
+
+// nextLink extracts the rel="next" URL from a Link header.
+func nextLink(link string) string {
+	if m := linkNextRegex.FindStringSubmatch(link); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// cacheKey identifies a response by URL and credentials, so that content
+// seen with one token is not served to another.
+func (c *client) cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url + "\x00" + c.token()))
+	return hex.EncodeToString(sum[:])
+}
+
+func cachePath(key string) string {
+	return filepath.Join(httpCacheDir, key+".json")
+}
+
+func readCached(key string) *cachedResponse {
+	if httpCacheDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(cachePath(key))
+	if err != nil {
+		return nil
+	}
+	var c cachedResponse
+	if json.Unmarshal(data, &c) != nil || c.ETag == "" {
+		return nil
+	}
+	return &c
+}
+
+// writeCached is best effort: a missing entry only costs a full request.
+func writeCached(key string, c *cachedResponse) {
+	if httpCacheDir == "" {
+		return
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(httpCacheDir, 0755); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(httpCacheDir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil && cerr == nil {
+		_ = os.Rename(f.Name(), cachePath(key))
+	}
+	os.Remove(f.Name())
+}
@@ -0,0 +1,255 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testGitHub returns a GitHub provider whose API is served by handler, with
+// a token so that no credential helper runs, and records the retry waits
+// instead of sleeping.
+func testGitHub(t *testing.T, handler http.HandlerFunc) (*GitHub, *[]time.Duration) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { sleep = time.Sleep })
+	SetCacheDir("")
+
+	g := newGitHub(Registry{URL: srv.URL, API: srv.URL, Repo: "acme/templates", Token: "test-token"})
+	return g, &waits
+}
+
+const dirsPage = `[{"name": "api", "type": "dir"}, {"name": "README.md", "type": "file"}]`
+
+func TestGetRetriesServerErrors(t *testing.T) {
+	var hits atomic.Int32
+	g, waits := testGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, dirsPage)
+	})
+
+	dirs, err := g.ListDirs("")
+	if err != nil {
+		t.Fatalf("ListDirs: %v", err)
+	}
+	if !reflect.DeepEqual(dirs, []string{"api"}) {
+		t.Errorf("dirs = %v, want [api]", dirs)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("requests = %d, want 3", hits.Load())
+	}
+	if len(*waits) != 2 || (*waits)[0] < baseBackoff || (*waits)[1] < 2*baseBackoff {
+		t.Errorf("waits = %v, want an exponential backoff from %s", *waits, baseBackoff)
+	}
+}
+
+func TestGetGivesUpAfterMaxAttempts(t *testing.T) {
+	var hits atomic.Int32
+	g, _ := testGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	_, err := g.ListDirs("")
+	if err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Errorf("err = %v, want API returned status 503", err)
+	}
+	if hits.Load() != maxAttempts {
+		t.Errorf("requests = %d, want %d", hits.Load(), maxAttempts)
+	}
+}
+
+func TestGetHonorsRetryAfter(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusForbidden} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			var hits atomic.Int32
+			g, waits := testGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+				if hits.Add(1) == 1 {
+					w.Header().Set("Retry-After", "7")
+					w.WriteHeader(status)
+					return
+				}
+				fmt.Fprint(w, dirsPage)
+			})
+
+			if _, err := g.ListDirs(""); err != nil {
+				t.Fatalf("ListDirs: %v", err)
+			}
+			if !reflect.DeepEqual(*waits, []time.Duration{7 * time.Second}) {
+				t.Errorf("waits = %v, want [7s]", *waits)
+			}
+		})
+	}
+}
+
+func TestGetHonorsRateLimitReset(t *testing.T) {
+	reset := time.Now().Add(30 * time.Second)
+	var hits atomic.Int32
+	g, waits := testGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, dirsPage)
+	})
+
+	if _, err := g.ListDirs(""); err != nil {
+		t.Fatalf("ListDirs: %v", err)
+	}
+	if len(*waits) != 1 || (*waits)[0] < 28*time.Second || (*waits)[0] > 32*time.Second {
+		t.Errorf("waits = %v, want about 31s (until the reset)", *waits)
+	}
+}
+
+func TestGetFailsWhenRateLimitResetIsFar(t *testing.T) {
+	reset := time.Now().Add(time.Hour)
+	var hits atomic.Int32
+	g, waits := testGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	_, err := g.ListDirs("")
+	var rlErr *RateLimitError
+	if !errors.As(err, &rlErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want a RateLimitError", err)
+	}
+	if rlErr.Reset.Unix() != reset.Unix() {
+		t.Errorf("Reset = %v, want %v", rlErr.Reset, reset)
+	}
+	if hits.Load() != 1 || len(*waits) != 0 {
+		t.Errorf("requests = %d, waits = %v; want 1 request and no wait", hits.Load(), *waits)
+	}
+}
+
+func TestGetDoesNotRetryPlainForbidden(t *testing.T) {
+	var hits atomic.Int32
+	g, _ := testGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	if _, err := g.ListDirs(""); err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("err = %v, want API returned status 403", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("requests = %d, want 1", hits.Load())
+	}
+}
+
+func TestGetRevalidatesCachedResponseWithETag(t *testing.T) {
+	var hits, notModified atomic.Int32
+	g, _ := testGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, dirsPage)
+	})
+	SetCacheDir(t.TempDir())
+	t.Cleanup(func() { SetCacheDir("") })
+
+	for i := 0; i < 2; i++ {
+		dirs, err := g.ListDirs("")
+		if err != nil {
+			t.Fatalf("ListDirs #%d: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(dirs, []string{"api"}) {
+			t.Errorf("ListDirs #%d = %v, want [api]", i+1, dirs)
+		}
+	}
+	if hits.Load() != 2 || notModified.Load() != 1 {
+		t.Errorf("requests = %d (%d not modified), want 2 (1 not modified)", hits.Load(), notModified.Load())
+	}
+}
+
+func TestGetCacheIsPerToken(t *testing.T) {
+	g, _ := testGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, dirsPage)
+	})
+	other := newGitHub(Registry{URL: g.web, API: g.api, Repo: g.repo, Token: "other-token"})
+
+	u := g.contentsURL("")
+	if g.client.cacheKey(u) == other.client.cacheKey(u) {
+		t.Error("responses seen with one token would be served to another")
+	}
+}
+
+func TestListDirsFollowsLinkPagination(t *testing.T) {
+	var srvURL string
+	g, _ := testGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			next := fmt.Sprintf("%s%s?per_page=100&page=%d", srvURL, r.URL.Path, page+1)
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next", <%s>; rel="last"`, next, next))
+		}
+		fmt.Fprintf(w, `[{"name": "dir%d", "type": "dir"}, {"name": "file%d", "type": "file"}]`, page, page)
+	})
+	srvURL = g.api
+
+	dirs, err := g.ListDirs("templates")
+	if err != nil {
+		t.Fatalf("ListDirs: %v", err)
+	}
+	if want := []string{"dir1", "dir2", "dir3"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("dirs = %v, want %v", dirs, want)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		link, want string
+	}{
+		{"", ""},
+		{`<https://api.example.com/x?page=2>; rel="next"`, "https://api.example.com/x?page=2"},
+		{`<https://a/x?page=1>; rel="prev", <https://a/x?page=3>; rel="next", <https://a/x?page=9>; rel="last"`, "https://a/x?page=3"},
+		{`<https://a/x?page=9>; rel="last"`, ""},
+	}
+	for _, tt := range tests {
+		if got := nextLink(tt.link); got != tt.want {
+			t.Errorf("nextLink(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestUnauthorizedNamesTokenSourceNotToken(t *testing.T) {
+	g, _ := testGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Authorization = %q, want the bearer token", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	_, err := g.ListDirs("")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, sourceConfig) || strings.Contains(msg, "test-token") {
+		t.Errorf("err = %q, want the token's source and not the token", msg)
+	}
+}
@@ -0,0 +1,102 @@
+// Package provider reads template and package repositories hosted on
+// GitHub (or GitHub Enterprise), GitLab, Gitea/Forgejo, or any git server.
+package provider
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Provider lists directories and reads files of one repository. Paths are
+// relative to the repository root, with forward slashes.
+type Provider interface {
+	// ListDirs returns the names of the directories directly under dir
+	// ("" for the root). A missing dir yields an empty list.
+	ListDirs(dir string) ([]string, error)
+	// GetFile returns the content of the file at path.
+	GetFile(path string) ([]byte, error)
+	// Link returns the web URL of path, for display.
+	Link(path string) string
+	// CloneURL returns the URL git clones the repository from.
+	CloneURL() string
+}
+
+// Archiver is implemented by providers that can download the repository
+// as a .tar.gz archive (used when git is not available).
+type Archiver interface {
+	Tarball() (io.ReadCloser, error)
+}
+
+// Provider types, as accepted in Registry.Type.
+const (
+	TypeGitHub = "github"
+	TypeGitLab = "gitlab"
+	TypeGitea  = "gitea"
+	TypeGit    = "git"
+)
+
+// Registry describes where a repository lives.
+type Registry struct {
+	// Type is TypeGitHub (default), TypeGitLab, TypeGitea or TypeGit.
+	Type string
+	// URL is the web base URL of the server (e.g. https://gitlab.example.com);
+	// for TypeGit it is the clone URL of the repository itself.
+	URL string
+	// API overrides the API base URL derived from URL.
+	API string
+	// Repo is the repository path on the server ("owner/name", or
+	// "group/subgroup/name" on GitLab).
+	Repo string
+	// Ref is the branch or tag to read; "" means the default branch.
+	Ref string
+	// Token authenticates API requests (and HTTPS clones); "" means it is
+	// looked up in the environment, gh, git credential helpers and netrc.
+	// $VAR references are expanded.
+	Token string
+	// Protocol is what git clones over: ProtocolHTTPS (default) or
+	// ProtocolSSH. TypeGit uses URL as is.
+	Protocol string
+}
+
+// Clone protocols, as accepted in Registry.Protocol.
+const (
+	ProtocolHTTPS = "https"
+	ProtocolSSH   = "ssh"
+)
+
+// New returns the provider for r. checkout returns the path of the local
+// git checkout of the repository (cloning it if needed); only TypeGit uses
+// it.
+func New(r Registry, checkout func() (string, error)) (Provider, error) {
+	r.URL = strings.TrimSuffix(r.URL, "/")
+	r.API = strings.TrimSuffix(r.API, "/")
+	switch r.Type {
+	case "", TypeGitHub:
+		return newGitHub(r), nil
+	case TypeGitLab:
+		if r.URL == "" {
+			r.URL = "https://gitlab.com"
+		}
+		return newGitLab(r), nil
+	case TypeGitea:
+		if r.URL == "" {
+			return nil, fmt.Errorf("registry type gitea requires url")
+		}
+		return newGitea(r), nil
+	case TypeGit:
+		if r.URL == "" {
+			return nil, fmt.Errorf("registry type git requires url (the clone URL)")
+		}
+		return &Git{url: r.URL, ref: r.Ref, checkout: checkout}, nil
+	}
+	return nil, fmt.Errorf("unknown registry type %q (expected github, gitlab, gitea or git)", r.Type)
+}
+
+// refOrHEAD returns ref, or "HEAD" (the default branch) when it is empty.
+func refOrHEAD(ref string) string {
+	if ref == "" {
+		return "HEAD"
+	}
+	return ref
+}
@@ -0,0 +1,204 @@
+// Package registry holds the repositories templates and packages are read
+// from (cosmos-toolkit/templates and cosmos-toolkit/packages on GitHub by
+// default, or the registries set in the config file).
+package registry
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/cosmos-toolkit/cli/internal/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// Repository kinds.
+const (
+	Templates = "templates"
+	Packages  = "packages"
+)
+
+// Defaults returns the built-in registry of kind.
+func Defaults(kind string) provider.Registry {
+	return provider.Registry{Type: provider.TypeGitHub, Repo: "cosmos-toolkit/" + kind}
+}
+
+var (
+	registries = map[string]provider.Registry{
+		Templates: Defaults(Templates),
+		Packages:  Defaults(Packages),
+	}
+	providers = map[string]provider.Provider{}
+)
+
+// Configure sets the registries of templates and packages. checkout is
+// given to registries of type git to read the local checkout of kind.
+func Configure(templates, packages provider.Registry, checkout func(kind string) (string, error)) error {
+	next := map[string]provider.Provider{}
+	for kind, r := range map[string]provider.Registry{Templates: templates, Packages: packages} {
+		kind := kind
+		p, err := provider.New(r, func() (string, error) { return checkout(kind) })
+		if err != nil {
+			return fmt.Errorf("registries.%s: %w", kind, err)
+		}
+		next[kind] = p
+	}
+	registries[Templates], registries[Packages] = templates, packages
+	providers = next
+	return nil
+}
+
+// Get returns the provider of kind (Templates or Packages).
+func Get(kind string) provider.Provider {
+	if p, ok := providers[kind]; ok {
+		return p
+	}
+	p, _ := provider.New(registries[kind], nil)
+	providers[kind] = p
+	return p
+}
+
+// Describe returns the registry settings of kind.
+func Describe(kind string) provider.Registry {
+	return registries[kind]
+}
+
+// GitEnv returns environment variables that give git the registry tokens,
+// as http.<clone url>.extraHeader entries (GIT_CONFIG_COUNT, git 2.31+), so
+// that they never appear on a command line. Entries already set in the
+// environment are kept.
+func GitEnv() []string {
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	var env []string
+	for _, kind := range []string{Templates, Packages} {
+		a, ok := Get(kind).(provider.Authenticator)
+		if !ok {
+			continue
+		}
+		for _, kv := range a.GitConfig() {
+			env = append(env,
+				fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n, kv[0]),
+				fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, kv[1]))
+			n++
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	return append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", n))
+}
+
+// TemplateInfo holds name, description, and link for display.
+type TemplateInfo struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	Link        string `json:"link" yaml:"link"`
+}
+
+// PackageInfo holds name, description, and link for display.
+type PackageInfo struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	Link        string `json:"link" yaml:"link"`
+}
+
+// manifest describes manifest.yaml at the repository root; templates and
+// packages each use their own key.
+type manifest struct {
+	Templates map[string]struct {
+		Description string `yaml:"description"`
+	} `yaml:"templates"`
+	Packages map[string]struct {
+		Description string `yaml:"description"`
+	} `yaml:"packages"`
+}
+
+// GetTemplatesManifest returns the manifest.yaml of the templates repository.
+func GetTemplatesManifest() ([]byte, error) {
+	return Get(Templates).GetFile("manifest.yaml")
+}
+
+// GetPackagesManifest returns the manifest.yaml of the packages repository.
+func GetPackagesManifest() ([]byte, error) {
+	return Get(Packages).GetFile("manifest.yaml")
+}
+
+// ListTemplatesWithInfo returns templates with description and link (from manifest if available).
+func ListTemplatesWithInfo() ([]TemplateInfo, error) {
+	names, err := Get(Templates).ListDirs("")
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions := make(map[string]string)
+	if data, err := GetTemplatesManifest(); err == nil {
+		var m manifest
+		if yaml.Unmarshal(data, &m) == nil {
+			for k, v := range m.Templates {
+				descriptions[k] = v.Description
+			}
+		}
+	}
+
+	templates := make([]TemplateInfo, 0, len(names))
+	for _, name := range names {
+		templates = append(templates, NewTemplateInfo(name, descriptions[name]))
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// ListPackagesWithInfo returns packages (pkg/ subdirectories) with
+// description and link (from manifest if available).
+func ListPackagesWithInfo() ([]PackageInfo, error) {
+	names, err := Get(Packages).ListDirs("pkg")
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions := make(map[string]string)
+	if data, err := GetPackagesManifest(); err == nil {
+		var m manifest
+		if yaml.Unmarshal(data, &m) == nil {
+			for k, v := range m.Packages {
+				descriptions[k] = v.Description
+			}
+		}
+	}
+
+	pkgs := make([]PackageInfo, 0, len(names))
+	for _, name := range names {
+		pkgs = append(pkgs, NewPackageInfo(name, descriptions[name]))
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	return pkgs, nil
+}
+
+// TemplateLink returns the web URL of a template.
+func TemplateLink(name string) string {
+	return Get(Templates).Link(name)
+}
+
+// PackageLink returns the web URL of a package.
+func PackageLink(name string) string {
+	return Get(Packages).Link("pkg/" + name)
+}
+
+// NewTemplateInfo returns the listing of template name, with "-" for an
+// empty description.
+func NewTemplateInfo(name, description string) TemplateInfo {
+	return TemplateInfo{Name: name, Description: orDash(description), Link: TemplateLink(name)}
+}
+
+// NewPackageInfo is like NewTemplateInfo for packages.
+func NewPackageInfo(name, description string) PackageInfo {
+	return PackageInfo{Name: name, Description: orDash(description), Link: PackageLink(name)}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,205 @@
+package renderer
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/cosmos-toolkit/cli/internal/loader"
+)
+
+// Engine renders the files of a template (files.engine).
+type Engine interface {
+	// IsTemplate reports whether the file at path is rendered by Render;
+	// other files are copied (with the module placeholder replaced).
+	IsTemplate(path string) bool
+	// RenderElem renders one element (a directory or file name) of a
+	// template path. An element that ranges over a list gives one PathElem
+	// per item, and none for an empty list.
+	RenderElem(elem string, ctx Context) ([]PathElem, error)
+	// Render executes the template file at path, whose content is data,
+	// with the project's partials.
+	Render(path string, data []byte, ctx Context, partials []Partial) ([]byte, error)
+}
+
+// PathElem is a rendered path element. Item is the list item it was
+// rendered for, when Ranged.
+type PathElem struct {
+	Name   string
+	Item   any
+	Ranged bool
+}
+
+// NewEngine returns the engine files declares (gotmpl when not set).
+func NewEngine(files loader.FileConfig) (Engine, error) {
+	switch files.Engine {
+	case loader.EngineGoTemplate, "":
+		return goTemplate{}, nil
+	case loader.EngineGoTemplateDelims:
+		if len(files.Delims) != 2 {
+			return nil, fmt.Errorf("engine %s needs files.delims", files.Engine)
+		}
+		return goTemplate{left: files.Delims[0], right: files.Delims[1]}, nil
+	case loader.EngineNone:
+		return verbatim{}, nil
+	}
+	return nil, fmt.Errorf("unknown template engine %q", files.Engine)
+}
+
+// goTemplate renders .tmpl files and path elements with text/template.
+// Empty delimiters are the default {{ and }}.
+type goTemplate struct {
+	left, right string
+}
+
+func (e goTemplate) IsTemplate(path string) bool {
+	return strings.HasSuffix(path, ".tmpl")
+}
+
+// pathItemFunc is called at the start of each iteration of a ranging path
+// element; it records the item and writes pathItemMark.
+const (
+	pathItemFunc = "cosmosPathItem"
+	pathItemMark = "\x00"
+)
+
+// RenderElem renders elem. An element that is a single {{range}} action
+// fans out: {{range .Values.services}}{{.}}-svc{{end}} gives one element
+// per service, and {{range .Values.services}} alone is short for
+// {{range .Values.services}}{{.}}{{end}}.
+func (e goTemplate) RenderElem(elem string, ctx Context) ([]PathElem, error) {
+	if left, _ := e.delims(); !strings.Contains(elem, left) {
+		return []PathElem{{Name: elem}}, nil
+	}
+
+	tmpl, err := e.parseElem(elem)
+	if err != nil {
+		return nil, err
+	}
+	var items []any
+	itemFuncs := template.FuncMap{pathItemFunc: func(item any) string {
+		items = append(items, item)
+		return pathItemMark
+	}}
+	tmpl.Funcs(itemFuncs)
+
+	ranged := false
+	if root := tmpl.Tree.Root; len(root.Nodes) == 1 {
+		if rn, ok := root.Nodes[0].(*parse.RangeNode); ok {
+			mark, err := template.New("mark").Funcs(itemFuncs).Parse("{{" + pathItemFunc + " .}}")
+			if err != nil {
+				return nil, err
+			}
+			rn.List.Nodes = append([]parse.Node{mark.Tree.Root.Nodes[0]}, rn.List.Nodes...)
+			ranged = true
+		}
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, ctx); err != nil {
+		return nil, err
+	}
+	if !ranged {
+		return []PathElem{{Name: buf.String()}}, nil
+	}
+	// Text before the first mark comes from an {{else}} branch: an empty
+	// list gives no element
+	names := strings.Split(buf.String(), pathItemMark)[1:]
+	out := make([]PathElem, len(items))
+	for i, item := range items {
+		out[i] = PathElem{Name: names[i], Item: item, Ranged: true}
+	}
+	return out, nil
+}
+
+// parseElem parses the path element elem, expanding the {{range}}
+// shorthand.
+func (e goTemplate) parseElem(elem string) (*template.Template, error) {
+	left, right := e.delims()
+	parseText := func(text string) (*template.Template, error) {
+		return template.New(elem).Delims(left, right).Option(missingKey).Funcs(funcs).Parse(text)
+	}
+	tmpl, err := parseText(elem)
+	if err != nil && isRangeStart(elem, left) && !strings.Contains(elem, left+"end") {
+		tmpl, err = parseText(elem + left + "." + right + left + "end" + right)
+	}
+	return tmpl, err
+}
+
+// isRangeStart reports whether elem starts with a range action.
+func isRangeStart(elem, left string) bool {
+	rest := strings.TrimPrefix(elem, left)
+	if rest == elem {
+		return false
+	}
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "-"), " \t")
+	return strings.HasPrefix(rest, "range ")
+}
+
+func (e goTemplate) delims() (string, string) {
+	if e.left == "" {
+		return "{{", "}}"
+	}
+	return e.left, e.right
+}
+
+// Render executes the file at path. Its errors are *FileError, located in
+// the file or in the partial that failed; Template is only set for the
+// latter.
+func (e goTemplate) Render(path string, data []byte, ctx Context, partials []Partial) ([]byte, error) {
+	tmpl := template.New(path).Option(missingKey).Funcs(funcs)
+	files := map[string]string{path: path}
+	owners := make(map[string]string)
+	for _, p := range partials {
+		// Partials are parsed with the delimiters of the template declaring
+		// them; those of other engines are not templates
+		pe, ok := p.Engine.(goTemplate)
+		if !ok {
+			continue
+		}
+		if _, err := tmpl.New(p.Name).Delims(pe.left, pe.right).Parse(p.Text); err != nil {
+			fe := templateError(err, map[string]string{p.Name: p.Path})
+			fe.Template = p.Template
+			return nil, fe
+		}
+		files[p.Name] = p.Path
+		owners[p.Path] = p.Template
+	}
+	if _, err := tmpl.Delims(e.left, e.right).Parse(string(data)); err != nil {
+		return nil, templateError(err, files)
+	}
+
+	// Execute template to buffer
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, ctx); err != nil {
+		fe := templateError(err, files)
+		if fe.Path != path {
+			fe.Template = owners[fe.Path]
+		}
+		return nil, fe
+	}
+
+	return []byte(buf.String()), nil
+}
+
+// missingKey makes a missing map key (e.g. an undeclared .Values key) an
+// error instead of "<no value>".
+const missingKey = "missingkey=error"
+
+func (e goTemplate) parse(name, text string) (*template.Template, error) {
+	return template.New(name).Delims(e.left, e.right).Option(missingKey).Funcs(funcs).Parse(text)
+}
+
+// verbatim copies files and paths as they are.
+type verbatim struct{}
+
+func (verbatim) IsTemplate(string) bool { return false }
+
+func (verbatim) RenderElem(elem string, _ Context) ([]PathElem, error) {
+	return []PathElem{{Name: elem}}, nil
+}
+
+func (verbatim) Render(_ string, data []byte, _ Context, _ []Partial) ([]byte, error) {
+	return data, nil
+}
@@ -0,0 +1,98 @@
+package renderer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FileError is a failure to render one file of a template: a template
+// that does not parse or execute, a path that does not render, or a file
+// that cannot be merged.
+type FileError struct {
+	// Template is the name of the template the file belongs to ("" when
+	// unknown).
+	Template string
+	// Path is the file's path in the template.
+	Path string
+	// Line and Col locate the failure in the file (0 when unknown).
+	Line, Col int
+	Msg       string
+}
+
+func (e *FileError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Path)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+		if e.Col > 0 {
+			fmt.Fprintf(&b, ":%d", e.Col)
+		}
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Msg)
+	if e.Template != "" {
+		fmt.Fprintf(&b, " (template %s)", e.Template)
+	}
+	return b.String()
+}
+
+// Errors are all the files of a render that failed.
+type Errors []*FileError
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := make([]string, len(e))
+	for i, fe := range e {
+		lines[i] = "  " + fe.Error()
+	}
+	return fmt.Sprintf("%d files failed to render:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// templateError turns an error of text/template into a FileError. files
+// maps the names of the templates involved (the file, its partials) to
+// their paths; the error names the one that failed.
+func templateError(err error, files map[string]string) *FileError {
+	msg := err.Error()
+	for name, p := range files {
+		prefix := "template: " + name + ":"
+		if !strings.HasPrefix(msg, prefix) {
+			continue
+		}
+		fe := &FileError{Path: p}
+		rest := strings.TrimPrefix(msg, prefix)
+		// line[:col]: message
+		fe.Line, rest = leadingInt(rest)
+		if fe.Line > 0 && strings.HasPrefix(rest, ":") {
+			fe.Col, rest = leadingInt(rest[1:])
+			if fe.Col == 0 {
+				rest = ":" + rest
+			}
+		}
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, ":"), " ")
+		// executing "name" at <.X>: ... says the same as the location
+		if strings.HasPrefix(rest, "executing ") {
+			if i := strings.Index(rest, " at <"); i >= 0 {
+				rest = rest[i+1:]
+			}
+		}
+		fe.Msg = rest
+		return fe
+	}
+	return &FileError{Msg: msg}
+}
+
+// leadingInt splits the decimal number at the start of s from the rest.
+func leadingInt(s string) (int, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return 0, s
+	}
+	return n, s[i:]
+}
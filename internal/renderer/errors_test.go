@@ -0,0 +1,97 @@
+package renderer
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"text/template"
+)
+
+func TestTemplateError(t *testing.T) {
+	files := map[string]string{
+		"main.go.tmpl": "cmd/main.go.tmpl",
+		"header":       "partials/header.tmpl",
+	}
+	tests := []struct {
+		name string
+		err  error
+		want FileError
+	}{
+		{
+			"parse error with line",
+			errors.New(`template: main.go.tmpl:3: unclosed action`),
+			FileError{Path: "cmd/main.go.tmpl", Line: 3, Msg: "unclosed action"},
+		},
+		{
+			"execution error with line and column",
+			errors.New(`template: main.go.tmpl:3:5: executing "main.go.tmpl" at <.Values.x>: map has no entry for key "x"`),
+			FileError{Path: "cmd/main.go.tmpl", Line: 3, Col: 5, Msg: `at <.Values.x>: map has no entry for key "x"`},
+		},
+		{
+			"error in a partial",
+			errors.New(`template: header:1:2: executing "header" at <.Nope>: can't evaluate field Nope in type renderer.Context`),
+			FileError{Path: "partials/header.tmpl", Line: 1, Col: 2, Msg: "at <.Nope>: can't evaluate field Nope in type renderer.Context"},
+		},
+		{
+			"unknown template",
+			errors.New(`template: other:1: bad`),
+			FileError{Msg: "template: other:1: bad"},
+		},
+		{
+			"not a template error",
+			errors.New("boom"),
+			FileError{Msg: "boom"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := templateError(tt.err, files); *got != tt.want {
+				t.Errorf("templateError = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateErrorFromTextTemplate(t *testing.T) {
+	files := map[string]string{"a.tmpl": "dir/a.tmpl"}
+
+	_, err := template.New("a.tmpl").Parse("line one\n{{if .X}}\n")
+	fe := templateError(err, files)
+	if fe.Path != "dir/a.tmpl" || fe.Line == 0 || fe.Msg == "" {
+		t.Errorf("parse error: %+v, want dir/a.tmpl with a line", *fe)
+	}
+
+	tmpl := template.Must(template.New("a.tmpl").Option(missingKey).Parse("ok\n  {{.Values.missing}}"))
+	err = tmpl.Execute(io.Discard, Context{Values: map[string]any{}})
+	fe = templateError(err, files)
+	want := FileError{Path: "dir/a.tmpl", Line: 2, Col: 11, Msg: `at <.Values.missing>: map has no entry for key "missing"`}
+	if *fe != want {
+		t.Errorf("execution error: %+v, want %+v", *fe, want)
+	}
+}
+
+func TestRenderLocatesErrorsInPartials(t *testing.T) {
+	partials := []Partial{{Name: "header", Path: "partials/header.tmpl", Template: "base", Text: "// {{.Values.title}}", Engine: goTemplate{}}}
+	_, err := goTemplate{}.Render("main.go.tmpl", []byte("{{template \"header\" .}}\npackage main\n"), Context{Values: map[string]any{}}, partials)
+	var fe *FileError
+	if !errors.As(err, &fe) {
+		t.Fatalf("Render = %v, want a *FileError", err)
+	}
+	if fe.Path != "partials/header.tmpl" || fe.Template != "base" || fe.Line != 1 {
+		t.Errorf("Render error = %+v, want partials/header.tmpl:1 of template base", *fe)
+	}
+	if got, want := fe.Error(), `partials/header.tmpl:1:12: at <.Values.title>: map has no entry for key "title" (template base)`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestErrorsError(t *testing.T) {
+	one := Errors{{Path: "a.go", Line: 2, Msg: "bad"}}
+	if got, want := one.Error(), "a.go:2: bad"; got != want {
+		t.Errorf("one error = %q, want %q", got, want)
+	}
+	two := Errors{{Path: "a.go", Msg: "bad"}, {Path: "b.go", Line: 1, Col: 3, Msg: "worse", Template: "base"}}
+	if got, want := two.Error(), "2 files failed to render:\n  a.go: bad\n  b.go:1:3: worse (template base)"; got != want {
+		t.Errorf("two errors = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,321 @@
+package renderer
+
+import (
+	"crypto/rand"
+	"fmt"
+	"go/token"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// allowEnv enables the env function; templates cannot read the environment
+// unless the user opts in (--allow-env), since values could end up in
+// generated files.
+var allowEnv bool
+
+// SetAllowEnv enables or disables the env template function.
+func SetAllowEnv(v bool) {
+	allowEnv = v
+}
+
+// funcs are available in file contents, path templates and hook commands.
+var funcs = template.FuncMap{
+	"lower":       strings.ToLower,
+	"upper":       strings.ToUpper,
+	"title":       titleCase,
+	"camel":       camelCase,
+	"pascal":      pascalCase,
+	"snake":       func(s string) string { return joinWords(words(s), "_", strings.ToLower) },
+	"kebab":       func(s string) string { return joinWords(words(s), "-", strings.ToLower) },
+	"upperSnake":  func(s string) string { return joinWords(words(s), "_", strings.ToUpper) },
+	"pluralize":   pluralize,
+	"singularize": singularize,
+	"goIdent":     goIdent,
+	"goPackage":   goPackage,
+	"quote":       strconv.Quote,
+	"indent":      indent,
+	"now":         time.Now,
+	"env":         env,
+	"uuid":        uuid,
+	"default":     defaultValue,
+	"join":        join,
+	"contains":    contains,
+}
+
+// words splits s into words at separators (anything but letters and
+// digits) and at case changes: "myHTTPServer" and "my-http_server" both
+// give my, http, server (case preserved).
+func words(s string) []string {
+	var out []string
+	var cur []rune
+	runes := []rune(s)
+	flush := func() {
+		if len(cur) > 0 {
+			out = append(out, string(cur))
+			cur = nil
+		}
+	}
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(cur) > 0 {
+			prev := cur[len(cur)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// aB splits before B; ABc splits before B (acronym, then word)
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return out
+}
+
+func joinWords(ws []string, sep string, f func(string) string) string {
+	for i, w := range ws {
+		ws[i] = f(w)
+	}
+	return strings.Join(ws, sep)
+}
+
+func capitalize(w string) string {
+	r := []rune(strings.ToLower(w))
+	if len(r) == 0 {
+		return ""
+	}
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// titleCase capitalizes each word, keeping the separators: "my app" gives
+// "My App".
+func titleCase(s string) string {
+	r := []rune(s)
+	start := true
+	for i, c := range r {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if start {
+				r[i] = unicode.ToUpper(c)
+			}
+			start = false
+		} else {
+			start = true
+		}
+	}
+	return string(r)
+}
+
+func pascalCase(s string) string {
+	return joinWords(words(s), "", capitalize)
+}
+
+func camelCase(s string) string {
+	ws := words(s)
+	for i, w := range ws {
+		if i == 0 {
+			ws[i] = strings.ToLower(w)
+		} else {
+			ws[i] = capitalize(w)
+		}
+	}
+	return strings.Join(ws, "")
+}
+
+// goIdent turns s into a valid, unexported-style Go identifier: "my-app"
+// gives "myApp", "1st" gives "_1st", "type" gives "type_".
+func goIdent(s string) string {
+	id := camelCase(s)
+	if id == "" {
+		return "_"
+	}
+	if unicode.IsDigit([]rune(id)[0]) {
+		id = "_" + id
+	}
+	if token.IsKeyword(id) {
+		id += "_"
+	}
+	return id
+}
+
+// goPackage turns s into a Go package name: lower case letters and digits
+// only ("my-app" gives "myapp").
+func goPackage(s string) string {
+	name := joinWords(words(s), "", strings.ToLower)
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "pkg" + name
+	}
+	if token.IsKeyword(name) {
+		name += "_"
+	}
+	return name
+}
+
+var irregularPlurals = map[string]string{
+	"person": "people", "child": "children", "man": "men", "woman": "women",
+	"mouse": "mice", "goose": "geese", "foot": "feet", "tooth": "teeth",
+	"datum": "data", "index": "indices", "matrix": "matrices",
+}
+
+var uncountable = map[string]bool{
+	"data": true, "info": true, "information": true, "metadata": true, "news": true,
+	"series": true, "species": true, "equipment": true, "feedback": true,
+}
+
+// pluralize returns the English plural of a singular word (best effort).
+func pluralize(s string) string {
+	lower := strings.ToLower(s)
+	if lower == "" || uncountable[lower] {
+		return s
+	}
+	if p, ok := irregularPlurals[lower]; ok {
+		return matchCase(s, p)
+	}
+	switch {
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsAny(lower[len(lower)-2:len(lower)-1], "aeiou"):
+		return s[:len(s)-1] + matchCase(s[len(s)-1:], "ies")
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return s + matchCase(s[len(s)-1:], "es")
+	}
+	return s + matchCase(s[len(s)-1:], "s")
+}
+
+// singularize is the inverse of pluralize (best effort).
+func singularize(s string) string {
+	lower := strings.ToLower(s)
+	if uncountable[lower] {
+		return s
+	}
+	for singular, plural := range irregularPlurals {
+		if lower == plural {
+			return matchCase(s, singular)
+		}
+	}
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(lower) > 3:
+		return s[:len(s)-3] + matchCase(s[len(s)-3:], "y")
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "zes"),
+		strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return s[:len(s)-2]
+	case strings.HasSuffix(lower, "ss"), strings.HasSuffix(lower, "us"):
+		return s
+	case strings.HasSuffix(lower, "s") && len(lower) > 1:
+		return s[:len(s)-1]
+	}
+	return s
+}
+
+// matchCase returns word upper-cased when ref is all upper case.
+func matchCase(ref, word string) string {
+	if ref != "" && strings.ToUpper(ref) == ref && strings.ToLower(ref) != ref {
+		return strings.ToUpper(word)
+	}
+	return word
+}
+
+// indent prefixes every non-empty line of s with n spaces.
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func env(name string) (string, error) {
+	if !allowEnv {
+		return "", fmt.Errorf("env %q: reading environment variables is disabled; pass --allow-env to enable it", name)
+	}
+	return os.Getenv(name), nil
+}
+
+// uuid returns a random (version 4) UUID.
+func uuid() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// defaultValue returns value, or def when value is empty (zero, "" or an
+// empty list): {{.Values.port | default 8080}}.
+func defaultValue(def, value any) any {
+	if isEmpty(value) {
+		return def
+	}
+	return value
+}
+
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+// join joins the elements of list (of any type) with sep.
+func join(sep string, list any) (string, error) {
+	items, err := toList(list)
+	if err != nil {
+		return "", fmt.Errorf("join: %w", err)
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = fmt.Sprint(item)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// contains reports whether haystack, a string or a list, contains needle:
+// {{if contains "http" $list}}.
+func contains(needle, haystack any) (bool, error) {
+	if s, ok := haystack.(string); ok {
+		return strings.Contains(s, fmt.Sprint(needle)), nil
+	}
+	items, err := toList(haystack)
+	if err != nil {
+		return false, fmt.Errorf("contains: %w", err)
+	}
+	for _, item := range items {
+		if fmt.Sprint(item) == fmt.Sprint(needle) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func toList(v any) ([]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", v)
+	}
+	items := make([]any, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, nil
+}
@@ -0,0 +1,153 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCaseFuncs(t *testing.T) {
+	tests := []struct {
+		in                                             string
+		camel, pascal, snake, kebab, upperSnake, title string
+	}{
+		{"my app", "myApp", "MyApp", "my_app", "my-app", "MY_APP", "My App"},
+		{"myHTTPServer", "myHttpServer", "MyHttpServer", "my_http_server", "my-http-server", "MY_HTTP_SERVER", "MyHTTPServer"},
+		{"my-http_server", "myHttpServer", "MyHttpServer", "my_http_server", "my-http-server", "MY_HTTP_SERVER", "My-Http_Server"},
+		{"UserID", "userId", "UserId", "user_id", "user-id", "USER_ID", "UserID"},
+		{"v2Api", "v2Api", "V2Api", "v2_api", "v2-api", "V2_API", "V2Api"},
+		{"", "", "", "", "", "", ""},
+	}
+	call := func(name, s string) string {
+		return funcs[name].(func(string) string)(s)
+	}
+	for _, tt := range tests {
+		for name, want := range map[string]string{
+			"camel": tt.camel, "pascal": tt.pascal, "snake": tt.snake,
+			"kebab": tt.kebab, "upperSnake": tt.upperSnake, "title": tt.title,
+		} {
+			if got := call(name, tt.in); got != want {
+				t.Errorf("%s(%q) = %q, want %q", name, tt.in, got, want)
+			}
+		}
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		singular, plural string
+	}{
+		{"user", "users"},
+		{"category", "categories"},
+		{"day", "days"},
+		{"box", "boxes"},
+		{"bus", "buses"},
+		{"match", "matches"},
+		{"wish", "wishes"},
+		{"person", "people"},
+		{"Child", "children"},
+		{"USER", "USERS"},
+		{"CATEGORY", "CATEGORIES"},
+		{"news", "news"},
+		{"Metadata", "Metadata"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := pluralize(tt.singular); got != tt.plural {
+			t.Errorf("pluralize(%q) = %q, want %q", tt.singular, got, tt.plural)
+		}
+	}
+
+	for _, tt := range []struct{ plural, singular string }{
+		{"users", "user"}, {"categories", "category"}, {"boxes", "box"},
+		{"classes", "class"}, {"people", "person"}, {"status", "status"}, {"", ""},
+	} {
+		if got := singularize(tt.plural); got != tt.singular {
+			t.Errorf("singularize(%q) = %q, want %q", tt.plural, got, tt.singular)
+		}
+	}
+}
+
+func TestGoIdent(t *testing.T) {
+	tests := []struct {
+		in, ident, pkg string
+	}{
+		{"my-app", "myApp", "myapp"},
+		{"My App", "myApp", "myapp"},
+		{"1st", "_1st", "pkg1st"},
+		{"type", "type_", "type_"},
+		{"func", "func_", "func_"},
+		{"--", "_", "pkg"},
+	}
+	for _, tt := range tests {
+		if got := goIdent(tt.in); got != tt.ident {
+			t.Errorf("goIdent(%q) = %q, want %q", tt.in, got, tt.ident)
+		}
+		if got := goPackage(tt.in); got != tt.pkg {
+			t.Errorf("goPackage(%q) = %q, want %q", tt.in, got, tt.pkg)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		needle, haystack any
+		want             bool
+	}{
+		{"http", "http-server", true},
+		{"grpc", "http-server", false},
+		{"http", []string{"grpc", "http"}, true},
+		{"http", []any{"grpc", "https"}, false},
+		{8080, []int{80, 8080}, true},
+		{"8080", []int{80, 8080}, true},
+		{"x", nil, false},
+		{"x", []string{}, false},
+	}
+	for _, tt := range tests {
+		got, err := contains(tt.needle, tt.haystack)
+		if err != nil || got != tt.want {
+			t.Errorf("contains(%v, %v) = %v, %v; want %v", tt.needle, tt.haystack, got, err, tt.want)
+		}
+	}
+	if _, err := contains("x", 42); err == nil || !strings.Contains(err.Error(), "expected a list") {
+		t.Errorf("contains of a number: err = %v, want expected a list", err)
+	}
+}
+
+func TestFuncsInTemplates(t *testing.T) {
+	ctx := Context{ProjectName: "order-service", Values: map[string]any{
+		"features": []any{"http", "metrics"},
+		"entity":   "category",
+		"port":     0,
+	}}
+	tests := []struct {
+		tmpl, want string
+	}{
+		{`{{if contains "http" .Values.features}}yes{{end}}`, "yes"},
+		{`{{.Values.entity | pluralize | pascal}}`, "Categories"},
+		{`package {{goPackage .ProjectName}}`, "package orderservice"},
+		{`{{.Values.port | default 8080}}`, "8080"},
+		{`{{join ", " .Values.features}}`, "http, metrics"},
+		{`{{indent 2 "a\n\nb"}}`, "  a\n\n  b"},
+		{`{{quote (upperSnake .ProjectName)}}`, `"ORDER_SERVICE"`},
+	}
+	for _, tt := range tests {
+		got, err := RenderString(tt.tmpl, ctx)
+		if err != nil || got != tt.want {
+			t.Errorf("RenderString(%q) = %q, %v; want %q", tt.tmpl, got, err, tt.want)
+		}
+	}
+}
+
+func TestEnvNeedsOptIn(t *testing.T) {
+	t.Setenv("COSMOS_TEST_VAR", "value")
+	t.Cleanup(func() { SetAllowEnv(false) })
+
+	SetAllowEnv(false)
+	if _, err := RenderString(`{{env "COSMOS_TEST_VAR"}}`, Context{}); err == nil || !strings.Contains(err.Error(), "--allow-env") {
+		t.Errorf("env without --allow-env: err = %v, want a hint at --allow-env", err)
+	}
+	SetAllowEnv(true)
+	if got, err := RenderString(`{{env "COSMOS_TEST_VAR"}}`, Context{}); err != nil || got != "value" {
+		t.Errorf("env = %q, %v; want value", got, err)
+	}
+}
@@ -0,0 +1,266 @@
+package renderer
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/cosmos-toolkit/cli/internal/writer"
+)
+
+// Reference is a use, at Path:Line, of a prompt value (.Values.<Key>, or
+// .Module and .ProjectName for the module and projectName keys) or, for
+// Inspection.Calls, of a named template.
+type Reference struct {
+	Key  string
+	Path string
+	Line int
+}
+
+// InspectedFile is a file of a layer.
+type InspectedFile struct {
+	// Path is the file's path in its layer; Template and Dir are those of
+	// the layer.
+	Path     string
+	Template string
+	Dir      string
+	// Generated is false for files the layer's rules exclude.
+	Generated bool
+	// Symlink is set for symbolic links, which are recreated rather than
+	// written.
+	Symlink bool
+	// Rendered, Verbatim and Text tell how a generated file is written:
+	// rendered by the engine, copied as is, or with the module placeholder
+	// replaced (a Text file that is neither).
+	Rendered, Verbatim, Text bool
+}
+
+// Inspection is what the files of some layers contain, found by parsing
+// them without rendering anything.
+type Inspection struct {
+	Files []InspectedFile
+	// Refs are the prompt values the templates use.
+	Refs []Reference
+	// Calls are the {{template "name"}} calls.
+	Calls []Reference
+	// Partials are the names of the partials and of the {{define}} blocks.
+	Partials map[string]bool
+	// Strings are the string constants of the templates.
+	Strings map[string]bool
+	// Errors are the files and path elements that do not parse.
+	Errors Errors
+}
+
+// Inspect parses the template files, partials and path templates of layers
+// as RenderLayers would render them.
+func Inspect(layers []Layer) (*Inspection, error) {
+	in := &Inspection{Partials: make(map[string]bool), Strings: make(map[string]bool)}
+	partials, err := collectPartials(layers)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range partials {
+		in.Partials[p.Name] = true
+		if g, ok := p.Engine.(goTemplate); ok {
+			in.parseFile(g, p.Path, p.Template, p.Text)
+		}
+	}
+
+	for _, layer := range layers {
+		engine := layer.Engine
+		if engine == nil {
+			engine = goTemplate{}
+		}
+		rules := layer.Rules
+		if rules == nil {
+			rules = defaultRules
+		}
+		g, isGo := engine.(goTemplate)
+		err := fs.WalkDir(layer.FS, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == "template.yaml" {
+				return nil
+			}
+			if d.IsDir() {
+				if p == PartialsDir || (p != "." && skipped(p, layer.Skip)) {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			file := InspectedFile{Path: p, Template: layer.Name, Dir: layer.Dir}
+			file.Generated = !rules.skipFile(p)
+			file.Symlink = d.Type()&fs.ModeSymlink != 0
+			file.Verbatim = rules.isVerbatim(p)
+			file.Rendered = !file.Verbatim && (engine.IsTemplate(p) || rules.isRendered(p))
+			file.Text = rules.isText(p)
+			in.Files = append(in.Files, file)
+			if !file.Generated || !isGo || file.Verbatim {
+				return nil
+			}
+
+			display := path.Join(layer.Dir, p)
+			for _, elem := range strings.Split(p, "/") {
+				tmpl, err := g.parseElem(elem)
+				if err != nil {
+					fe := templateError(err, map[string]string{elem: display})
+					in.Errors = append(in.Errors, &FileError{Template: layer.Name, Path: display, Msg: fmt.Sprintf("path element %q: %s", elem, fe.Msg)})
+					continue
+				}
+				in.walkTrees(tmpl, display)
+			}
+
+			if !d.Type().IsRegular() || !file.Rendered {
+				return nil
+			}
+			data, err := fs.ReadFile(layer.FS, p)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", display, err)
+			}
+			if !writer.IsBinary(data) {
+				in.parseFile(g, display, layer.Name, string(data))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+// AddText inspects text, a template that is not a file (e.g. a hook
+// command), reporting it as name.
+func (in *Inspection) AddText(name, text string) {
+	in.parseFile(goTemplate{}, name, "", text)
+}
+
+func (in *Inspection) parseFile(g goTemplate, display, templateName, text string) {
+	tmpl, err := g.parse(display, text)
+	if err != nil {
+		fe := templateError(err, map[string]string{display: display})
+		if fe.Path == "" {
+			fe.Path = display
+		}
+		fe.Template = templateName
+		in.Errors = append(in.Errors, fe)
+		return
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Name() != display {
+			in.Partials[t.Name()] = true
+		}
+	}
+	in.walkTrees(tmpl, display)
+}
+
+// walkTrees records what the templates of tmpl, parsed from the file
+// display, use.
+func (in *Inspection) walkTrees(tmpl *template.Template, display string) {
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		tree := t.Tree
+		lineOf := func(n parse.Node) int {
+			loc, _ := tree.ErrorContext(n)
+			// loc is name:line:col
+			parts := strings.Split(loc, ":")
+			if len(parts) < 3 {
+				return 0
+			}
+			l, _ := strconv.Atoi(parts[len(parts)-2])
+			return l
+		}
+		walkNode(tree.Root, func(n parse.Node) {
+			switch n := n.(type) {
+			case *parse.FieldNode:
+				if key := valueKey(n.Ident); key != "" {
+					in.Refs = append(in.Refs, Reference{Key: key, Path: display, Line: lineOf(n)})
+				}
+			case *parse.VariableNode:
+				if len(n.Ident) > 1 && n.Ident[0] == "$" {
+					if key := valueKey(n.Ident[1:]); key != "" {
+						in.Refs = append(in.Refs, Reference{Key: key, Path: display, Line: lineOf(n)})
+					}
+				}
+			case *parse.StringNode:
+				in.Strings[n.Text] = true
+			case *parse.TemplateNode:
+				in.Calls = append(in.Calls, Reference{Key: n.Name, Path: display, Line: lineOf(n)})
+			}
+		})
+	}
+}
+
+// valueKey returns the prompt key a field chain of the render Context
+// refers to ("" for none).
+func valueKey(ident []string) string {
+	switch {
+	case len(ident) >= 2 && ident[0] == "Values":
+		return ident[1]
+	case len(ident) >= 1 && ident[0] == "Module":
+		return "module"
+	case len(ident) >= 1 && ident[0] == "ProjectName":
+		return "projectName"
+	}
+	return ""
+}
+
+// walkNode calls f for n and every node below it.
+func walkNode(n parse.Node, f func(parse.Node)) {
+	if n == nil {
+		return
+	}
+	f(n)
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walkNode(c, f)
+		}
+	case *parse.ActionNode:
+		walkNode(n.Pipe, f)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, d := range n.Decl {
+			walkNode(d, f)
+		}
+		for _, c := range n.Cmds {
+			walkNode(c, f)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			walkNode(a, f)
+		}
+	case *parse.ChainNode:
+		walkNode(n.Node, f)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, f)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, f)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, f)
+	case *parse.TemplateNode:
+		walkNode(n.Pipe, f)
+	}
+}
+
+func walkBranch(b *parse.BranchNode, f func(parse.Node)) {
+	walkNode(b.Pipe, f)
+	if b.List != nil {
+		walkNode(b.List, f)
+	}
+	if b.ElseList != nil {
+		walkNode(b.ElseList, f)
+	}
+}
@@ -0,0 +1,425 @@
+package renderer
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mergeFile combines data, rendered by a later layer, with the file an
+// earlier layer wrote at path:
+//
+//   - go.mod: module comes from the base; the higher go version wins; the
+//     entries of every other directive (require, replace, tool, godebug...)
+//     are combined, the later layer's winning for the same module, tool or
+//     setting. Comments are kept with the line below them.
+//   - Makefile: .PHONY targets are combined; a target or variable the later
+//     layer defines replaces the base one in place; new variables go before
+//     the rules and new targets at the end.
+//   - README.md: the later layer's text is appended as further sections.
+//   - .gitignore: lines missing from the base are appended.
+//
+// Any other file is replaced.
+func mergeFile(path string, data []byte) ([]byte, error) {
+	var merge func(base, next string) string
+	switch name := filepath.Base(path); {
+	case name == "go.mod":
+		merge = mergeGoMod
+	case name == "Makefile" || name == "makefile" || name == "GNUmakefile":
+		merge = mergeMakefile
+	case strings.EqualFold(name, "README.md"):
+		merge = mergeReadme
+	case name == ".gitignore":
+		merge = mergeLines
+	default:
+		return data, nil
+	}
+	base, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(merge(string(base), string(data))), nil
+}
+
+func mergeReadme(base, next string) string {
+	return strings.TrimRight(base, "\n") + "\n\n" + strings.TrimLeft(next, "\n")
+}
+
+func mergeLines(base, next string) string {
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(base, "\n") {
+		seen[strings.TrimSpace(line)] = true
+	}
+	var added []string
+	for _, line := range strings.Split(next, "\n") {
+		key := strings.TrimSpace(line)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		added = append(added, line)
+	}
+	if len(added) == 0 {
+		return base
+	}
+	return strings.TrimRight(base, "\n") + "\n\n" + strings.Join(added, "\n") + "\n"
+}
+
+// goMod is a parsed go.mod: the module, go and toolchain lines, and the
+// entries of every other directive keyed by what they are about (module
+// path, tool, godebug setting), in the order the directives appear.
+type goMod struct {
+	module, goVersion, toolchain goModEntry
+	directives                   []string
+	entries                      map[string][]goModEntry
+	// comments are the comment lines above each directive's block;
+	// trailing are the comment lines at the end of the file.
+	comments map[string][]string
+	trailing []string
+}
+
+// goModEntry is one line, with the comment lines above it.
+type goModEntry struct {
+	key, text string
+	comments  []string
+}
+
+// goModOrder is the output order of the directives go.mod usually has;
+// others follow in the order they appear.
+var goModOrder = []string{"godebug", "require", "replace", "exclude", "retract", "tool", "ignore"}
+
+func parseGoMod(s string) *goMod {
+	m := &goMod{entries: make(map[string][]goModEntry), comments: make(map[string][]string)}
+	block := ""
+	var comments []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "//"):
+			comments = append(comments, line)
+			continue
+		case block != "":
+			if line == ")" {
+				block = ""
+				m.trailing = append(m.trailing, comments...)
+				comments = nil
+				continue
+			}
+			m.add(block, line, comments)
+			comments = nil
+			continue
+		}
+		directive, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		switch directive {
+		case "module":
+			m.module = goModEntry{text: rest, comments: comments}
+		case "go":
+			m.goVersion = goModEntry{text: rest, comments: comments}
+		case "toolchain":
+			m.toolchain = goModEntry{text: rest, comments: comments}
+		default:
+			if rest == "(" {
+				block = directive
+				m.addDirective(directive)
+				m.comments[directive] = append(m.comments[directive], comments...)
+			} else {
+				m.add(directive, rest, comments)
+			}
+		}
+		comments = nil
+	}
+	m.trailing = append(m.trailing, comments...)
+	return m
+}
+
+func (m *goMod) addDirective(directive string) {
+	for _, d := range m.directives {
+		if d == directive {
+			return
+		}
+	}
+	m.directives = append(m.directives, directive)
+}
+
+// add records an entry of directive. An entry with no text, like a bare
+// "require" line, is dropped.
+func (m *goMod) add(directive, text string, comments []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		m.trailing = append(m.trailing, comments...)
+		return
+	}
+	key := text
+	switch directive {
+	case "require", "replace", "exclude", "tool", "ignore":
+		key = fields[0]
+	case "godebug":
+		key, _, _ = strings.Cut(fields[0], "=")
+	}
+	m.addDirective(directive)
+	m.entries[directive] = append(m.entries[directive], goModEntry{key: key, text: text, comments: comments})
+}
+
+// order returns the directives of m in output order.
+func (m *goMod) order() []string {
+	var out []string
+	for _, d := range goModOrder {
+		if len(m.entries[d]) > 0 {
+			out = append(out, d)
+		}
+	}
+	for _, d := range m.directives {
+		if len(m.entries[d]) > 0 && !containsString(goModOrder, d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func mergeGoMod(base, next string) string {
+	b, n := parseGoMod(base), parseGoMod(next)
+	if versionLess(b.goVersion.text, n.goVersion.text) {
+		b.goVersion = n.goVersion
+	}
+	if n.toolchain.text != "" {
+		b.toolchain = n.toolchain
+	}
+	for _, d := range n.directives {
+		b.addDirective(d)
+		for _, e := range n.entries[d] {
+			b.entries[d] = replaceOrAppend(b.entries[d], e)
+		}
+		b.comments[d] = appendMissing(b.comments[d], n.comments[d])
+	}
+	b.trailing = appendMissing(b.trailing, n.trailing)
+
+	var out strings.Builder
+	writeComments(&out, b.module.comments, "")
+	out.WriteString("module " + b.module.text + "\n")
+	for _, l := range []struct {
+		directive string
+		e         goModEntry
+	}{{"go", b.goVersion}, {"toolchain", b.toolchain}} {
+		if l.e.text != "" {
+			out.WriteString("\n")
+			writeComments(&out, l.e.comments, "")
+			out.WriteString(l.directive + " " + l.e.text + "\n")
+		}
+	}
+	for _, d := range b.order() {
+		entries := b.entries[d]
+		out.WriteString("\n")
+		writeComments(&out, b.comments[d], "")
+		if len(entries) == 1 && len(b.comments[d]) == 0 {
+			writeComments(&out, entries[0].comments, "")
+			out.WriteString(d + " " + entries[0].text + "\n")
+			continue
+		}
+		out.WriteString(d + " (\n")
+		for _, e := range entries {
+			writeComments(&out, e.comments, "\t")
+			out.WriteString("\t" + e.text + "\n")
+		}
+		out.WriteString(")\n")
+	}
+	if len(b.trailing) > 0 {
+		out.WriteString("\n")
+		writeComments(&out, b.trailing, "")
+	}
+	return out.String()
+}
+
+func writeComments(out *strings.Builder, comments []string, indent string) {
+	for _, c := range comments {
+		out.WriteString(indent + c + "\n")
+	}
+}
+
+// replaceOrAppend puts e in place of the entry with the same key, keeping
+// that entry's comments when e has none, or appends it.
+func replaceOrAppend(entries []goModEntry, e goModEntry) []goModEntry {
+	for i := range entries {
+		if entries[i].key == e.key {
+			if len(e.comments) == 0 {
+				e.comments = entries[i].comments
+			}
+			entries[i] = e
+			return entries
+		}
+	}
+	return append(entries, e)
+}
+
+// appendMissing appends the lines of next that base does not have.
+func appendMissing(base, next []string) []string {
+	for _, l := range next {
+		if !containsString(base, l) {
+			base = append(base, l)
+		}
+	}
+	return base
+}
+
+// versionLess compares dotted versions such as "1.22" and "1.23.4".
+func versionLess(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+var (
+	makeVarRegex  = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.-]*)\s*(\?=|::=|:=|\+=|!=|=)`)
+	makeRuleRegex = regexp.MustCompile(`^([^\s:#=][^:=]*?)\s*::?($|[^=])`)
+)
+
+// makeItem is a variable or rule of a Makefile, with the comment lines
+// above it and, for rules, its recipe.
+type makeItem struct {
+	key      string // "var X" or "rule X"; "" for items that are never replaced
+	rule     bool
+	variable bool // an assignment, including += appends
+	lines    []string
+}
+
+// parseMakefile splits a Makefile into its .PHONY targets and its items.
+func parseMakefile(s string) (phony []string, items []makeItem) {
+	var pending []string // comments waiting for the item they describe
+	var current *makeItem
+	flush := func() {
+		if current != nil {
+			items = append(items, *current)
+			current = nil
+		}
+	}
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		if current != nil && current.rule && strings.HasPrefix(line, "\t") {
+			current.lines = append(current.lines, line)
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+			if len(pending) > 0 {
+				items = append(items, makeItem{lines: pending})
+				pending = nil
+			}
+		case strings.HasPrefix(trimmed, "#"):
+			flush()
+			pending = append(pending, line)
+		case strings.HasPrefix(line, ".PHONY:"):
+			flush()
+			phony = append(phony, strings.Fields(strings.TrimPrefix(line, ".PHONY:"))...)
+		case makeVarRegex.MatchString(line):
+			flush()
+			m := makeVarRegex.FindStringSubmatch(line)
+			key := "var " + m[1]
+			if m[2] == "+=" {
+				key = "" // appends are kept from every layer
+			}
+			items = append(items, makeItem{key: key, variable: true, lines: append(pending, line)})
+			pending = nil
+		case makeRuleRegex.MatchString(line):
+			flush()
+			m := makeRuleRegex.FindStringSubmatch(line)
+			current = &makeItem{key: "rule " + strings.TrimSpace(m[1]), rule: true, lines: append(pending, line)}
+			pending = nil
+		default:
+			flush()
+			items = append(items, makeItem{lines: append(pending, line)})
+			pending = nil
+		}
+	}
+	flush()
+	if len(pending) > 0 {
+		items = append(items, makeItem{lines: pending})
+	}
+	return phony, items
+}
+
+func mergeMakefile(base, next string) string {
+	phony, items := parseMakefile(base)
+	nextPhony, nextItems := parseMakefile(next)
+	for _, p := range nextPhony {
+		if !containsString(phony, p) {
+			phony = append(phony, p)
+		}
+	}
+	for _, it := range nextItems {
+		replaced := false
+		if it.key != "" {
+			for i := range items {
+				if items[i].key == it.key {
+					items[i] = it
+					replaced = true
+					break
+				}
+			}
+		}
+		if !replaced {
+			items = insertItem(items, it)
+		}
+	}
+
+	var out strings.Builder
+	if len(phony) > 0 {
+		out.WriteString(".PHONY: " + strings.Join(phony, " ") + "\n")
+	}
+	prevRule := true
+	for i, it := range items {
+		// Rules are separated by a blank line; consecutive variables are not.
+		if out.Len() > 0 && (it.rule || prevRule || i == 0) {
+			out.WriteString("\n")
+		}
+		out.WriteString(strings.Join(it.lines, "\n") + "\n")
+		prevRule = !it.variable
+	}
+	return out.String()
+}
+
+// insertItem appends a rule, and puts a variable after the last variable
+// (or before the first rule) so it is set before the rules that use it.
+func insertItem(items []makeItem, it makeItem) []makeItem {
+	if it.rule {
+		return append(items, it)
+	}
+	at := len(items)
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].variable {
+			at = i + 1
+			break
+		}
+		if items[i].rule {
+			at = i
+		}
+	}
+	items = append(items, makeItem{})
+	copy(items[at+1:], items[at:])
+	items[at] = it
+	return items
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
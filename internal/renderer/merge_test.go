@@ -0,0 +1,261 @@
+package renderer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeGoMod(t *testing.T) {
+	tests := []struct {
+		name, base, next, want string
+	}{
+		{
+			name: "requires combined, later layer wins",
+			base: `module example.com/app
+
+go 1.22
+
+require (
+	github.com/a/a v1.0.0
+	github.com/b/b v1.0.0 // indirect
+)
+`,
+			next: `module github.com/your-org/your-app
+
+go 1.23.1
+
+require github.com/a/a v1.2.0
+require github.com/c/c v0.1.0
+`,
+			want: `module example.com/app
+
+go 1.23.1
+
+require (
+	github.com/a/a v1.2.0
+	github.com/b/b v1.0.0 // indirect
+	github.com/c/c v0.1.0
+)
+`,
+		},
+		{
+			name: "higher go version of the base is kept; toolchain from the later layer",
+			base: "module m\n\ngo 1.24\n\ntoolchain go1.24.0\n",
+			next: "module m\n\ngo 1.23\n\ntoolchain go1.24.2\n",
+			want: "module m\n\ngo 1.24\n\ntoolchain go1.24.2\n",
+		},
+		{
+			name: "every directive kept, in go.mod order",
+			base: `module m
+
+go 1.24
+
+tool golang.org/x/tools/cmd/stringer
+
+require golang.org/x/tools v0.30.0
+
+replace example.com/old => ../old
+`,
+			next: `module m
+
+ignore ./node_modules
+
+godebug (
+	panicnil=1
+)
+
+exclude example.com/bad v1.0.0
+
+retract v0.9.0 // published by mistake
+
+tool github.com/golangci/golangci-lint/cmd/golangci-lint
+`,
+			want: `module m
+
+go 1.24
+
+godebug panicnil=1
+
+require golang.org/x/tools v0.30.0
+
+replace example.com/old => ../old
+
+exclude example.com/bad v1.0.0
+
+retract v0.9.0 // published by mistake
+
+tool (
+	golang.org/x/tools/cmd/stringer
+	github.com/golangci/golangci-lint/cmd/golangci-lint
+)
+
+ignore ./node_modules
+`,
+		},
+		{
+			name: "godebug keyed by setting",
+			base: "module m\n\ngodebug (\n\tpanicnil=1\n\thttp2client=0\n)\n",
+			next: "module m\n\ngodebug panicnil=0\n",
+			want: "module m\n\ngodebug (\n\tpanicnil=0\n\thttp2client=0\n)\n",
+		},
+		{
+			name: "comments kept with their lines",
+			base: `// Package app is the service.
+module m
+
+go 1.24
+
+// Pinned until the API settles.
+require example.com/a v1.0.0
+
+// trailing note
+`,
+			next: `module m
+
+// Tools used by make generate.
+tool (
+	// Generates String methods.
+	golang.org/x/tools/cmd/stringer
+)
+
+require example.com/a v1.1.0
+`,
+			want: `// Package app is the service.
+module m
+
+go 1.24
+
+// Pinned until the API settles.
+require example.com/a v1.1.0
+
+// Tools used by make generate.
+tool (
+	// Generates String methods.
+	golang.org/x/tools/cmd/stringer
+)
+
+// trailing note
+`,
+		},
+		{
+			name: "bare directive lines and empty blocks",
+			base: "module m\n\nrequire\n\nrequire (\n)\n",
+			next: "module m\n\ntool\nrequire example.com/a v1.0.0\n",
+			want: "module m\n\nrequire example.com/a v1.0.0\n",
+		},
+		{
+			name: "unknown directive after the known ones",
+			base: "module m\n\nfuture thing\n\nrequire example.com/a v1.0.0\n",
+			next: "module m\n",
+			want: "module m\n\nrequire example.com/a v1.0.0\n\nfuture thing\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeGoMod(tt.base, tt.next); got != tt.want {
+				t.Errorf("mergeGoMod =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeMakefile(t *testing.T) {
+	tests := []struct {
+		name, base, next, want string
+	}{
+		{
+			name: "targets and variables replaced in place or added",
+			base: `.PHONY: build test
+APP := app
+
+build:
+	go build -o bin/$(APP) ./cmd/$(APP)
+
+test:
+	go test ./...
+`,
+			next: `.PHONY: lint test
+GOFLAGS := -v
+
+# Run the tests with the race detector
+test:
+	go test -race ./...
+
+lint:
+	golangci-lint run
+`,
+			want: `.PHONY: build test lint
+
+APP := app
+GOFLAGS := -v
+
+build:
+	go build -o bin/$(APP) ./cmd/$(APP)
+
+# Run the tests with the race detector
+test:
+	go test -race ./...
+
+lint:
+	golangci-lint run
+`,
+		},
+		{
+			name: "variable redefined, appends kept",
+			base: "APP ?= app\nLDFLAGS += -s\n\nbuild:\n\tgo build\n",
+			next: "APP ?= svc\nLDFLAGS += -w\n",
+			want: "APP ?= svc\nLDFLAGS += -s\nLDFLAGS += -w\n\nbuild:\n\tgo build\n",
+		},
+		{
+			name: "double-colon rule and variable with colon",
+			base: "URL := http://example.com\n\nclean::\n\trm -rf bin\n",
+			next: "clean::\n\trm -rf dist\n",
+			want: "URL := http://example.com\n\nclean::\n\trm -rf dist\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeMakefile(tt.base, tt.next); got != tt.want {
+				t.Errorf("mergeMakefile =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeLines(t *testing.T) {
+	got := mergeLines("bin/\n*.log\n", "*.log\n\n.env\nbin/\n")
+	if want := "bin/\n*.log\n\n.env\n"; got != want {
+		t.Errorf("mergeLines = %q, want %q", got, want)
+	}
+	if got := mergeLines("bin/\n", "bin/\n"); got != "bin/\n" {
+		t.Errorf("mergeLines with nothing new = %q, want the base", got)
+	}
+}
+
+func TestMergeFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	tests := []struct {
+		name, base, next, want string
+	}{
+		{"README.md", "# App\n\n", "\n## Database\n", "# App\n\n## Database\n"},
+		{".gitignore", "bin/\n", ".env\n", "bin/\n\n.env\n"},
+		{"main.go", "package main\n", "package app\n", "package app\n"},
+	}
+	for _, tt := range tests {
+		got, err := mergeFile(write(tt.name, tt.base), []byte(tt.next))
+		if err != nil {
+			t.Fatalf("mergeFile(%s): %v", tt.name, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("mergeFile(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package renderer
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// PartialsDir is the directory of a template (or overlay) holding partials:
+// snippets every template file of the project can include by name, e.g.
+// {{template "license" .}} for _partials/license.tmpl. It is never
+// generated.
+const PartialsDir = "_partials"
+
+// Partial is a named snippet from a PartialsDir.
+type Partial struct {
+	// Name is the path in PartialsDir without the .tmpl suffix.
+	Name string
+	// Path is the file the partial comes from and Template the name of
+	// its template, for errors.
+	Path     string
+	Template string
+	Text     string
+	// Engine is the engine of the layer that declares the partial; it
+	// decides how the partial is parsed.
+	Engine Engine
+}
+
+// collectPartials returns the partials of layers; a partial of a later
+// layer replaces one of the same name.
+func collectPartials(layers []Layer) ([]Partial, error) {
+	var partials []Partial
+	index := make(map[string]int)
+	for _, layer := range layers {
+		if info, err := fs.Stat(layer.FS, PartialsDir); err != nil || !info.IsDir() {
+			continue
+		}
+		engine := layer.Engine
+		if engine == nil {
+			engine = goTemplate{}
+		}
+		err := fs.WalkDir(layer.FS, PartialsDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return fmt.Errorf("%s: partials must be regular files", p)
+			}
+			data, err := fs.ReadFile(layer.FS, p)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", p, err)
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(p, PartialsDir+"/"), ".tmpl")
+			partial := Partial{Name: name, Path: path.Join(layer.Dir, p), Template: layer.Name, Text: string(data), Engine: engine}
+			if i, ok := index[name]; ok {
+				partials[i] = partial
+			} else {
+				index[name] = len(partials)
+				partials = append(partials, partial)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return partials, nil
+}
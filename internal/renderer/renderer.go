@@ -1,11 +1,13 @@
 package renderer
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
-	"text/template"
 
 	"github.com/cosmos-toolkit/cli/internal/writer"
 )
@@ -15,9 +17,89 @@ type Context struct {
 	Module            string
 	GoVersion         string
 	ModulePlaceholder string // e.g. "github.com/your-org/your-app" - replaced in non-.tmpl text files (external templates)
+	// Values are the answers to the template's prompts, by key.
+	Values map[string]any
+	// Item is the list item of the path element ranging over a list
+	// ({{range .Values.services}}) the file is generated under.
+	Item any
+}
+
+// Layer is one directory of template files. Layers are rendered in order
+// into the same output directory: a file from a later layer replaces the
+// earlier one, except for the files merged by mergeFile.
+type Layer struct {
+	FS fs.FS
+	// ModulePlaceholder is replaced with the module in this layer's text
+	// files ("" for none).
+	ModulePlaceholder string
+	// Skip lists directories of FS that are not rendered (e.g. overlays,
+	// which are layers of their own).
+	Skip []string
+	// Engine renders this layer's files (gotmpl when nil).
+	Engine Engine
+	// Rules select the files generated and how (defaults when nil).
+	Rules *Rules
+	// Name is the name of the layer's template and Dir the layer's
+	// directory in it ("" for its root), for errors.
+	Name string
+	Dir  string
 }
 
 func Render(fsys fs.FS, ctx Context, outputDir string) error {
+	return RenderLayers([]Layer{{FS: fsys, ModulePlaceholder: ctx.ModulePlaceholder}}, ctx, outputDir)
+}
+
+// RenderLayers renders layers in order into outputDir. Files that fail to
+// render do not stop the others: their failures are returned together as
+// Errors. Other errors (e.g. writing a file) stop rendering.
+func RenderLayers(layers []Layer, ctx Context, outputDir string) error {
+	partials, err := collectPartials(layers)
+	if err != nil {
+		return err
+	}
+	written := make(map[string]bool)
+	var errs Errors
+	for _, layer := range layers {
+		layerCtx := ctx
+		layerCtx.ModulePlaceholder = layer.ModulePlaceholder
+		if err := renderLayer(layer, layerCtx, outputDir, partials, written, &errs); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func renderLayer(layer Layer, ctx Context, outputDir string, partials []Partial, written map[string]bool, errs *Errors) error {
+	fsys := layer.FS
+	engine := layer.Engine
+	if engine == nil {
+		engine = goTemplate{}
+	}
+	rules := layer.Rules
+	if rules == nil {
+		rules = defaultRules
+	}
+	// collect records the failure of the file at p and goes on with the
+	// next one; other errors are returned
+	collect := func(p string, err error) error {
+		var fe *FileError
+		if !errors.As(err, &fe) {
+			return err
+		}
+		if fe.Template == "" {
+			// A failure of the file itself, not of a partial
+			if fe.Path == "" {
+				fe.Path = p
+			}
+			fe.Template = layer.Name
+			fe.Path = path.Join(layer.Dir, fe.Path)
+		}
+		*errs = append(*errs, fe)
+		return nil
+	}
 	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -29,72 +111,229 @@ func Render(fsys fs.FS, ctx Context, outputDir string) error {
 		}
 
 		if d.IsDir() {
+			if path == PartialsDir || (path != "." && (skipped(path, layer.Skip) || rules.skipDir(path))) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if rules.skipFile(path) {
 			return nil
 		}
 
-		// Determine output path (support dynamic paths)
-		resolvedPath := resolvePath(path, ctx)
-		outputPath := filepath.Join(outputDir, resolvedPath)
+		if d.Type()&fs.ModeSymlink != 0 {
+			return collect(path, renderSymlink(fsys, path, engine, rules, ctx, outputDir, written))
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%s: unsupported file type %s", path, d.Type())
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
 
 		// Read file content
-		data, err := fs.ReadFile(fsys, path)
+		source, err := fs.ReadFile(fsys, path)
 		if err != nil {
 			return fmt.Errorf("failed to read %s: %w", path, err)
 		}
 
-		// Check if it's a template file
-		if strings.HasSuffix(path, ".tmpl") {
-			return renderTemplate(data, outputPath, ctx)
+		// Verbatim files keep their name and content
+		if rules.isVerbatim(path) {
+			return collect(path, writeOutput(outputDir, path, path, source, fileMode(info.Mode()), written))
 		}
 
-		// For external templates: replace module placeholder in text files
-		if ctx.ModulePlaceholder != "" && isTextFile(path) {
-			data = []byte(strings.ReplaceAll(string(data), ctx.ModulePlaceholder, ctx.Module))
+		// Determine output paths (support dynamic paths)
+		targets, err := expandPath(engine, path, ctx)
+		if err != nil {
+			return collect(path, err)
 		}
-
-		return writer.WriteFile(outputPath, data)
+		for _, t := range targets {
+			data := source
+			switch {
+			case writer.IsBinary(data):
+				// Binary content is never rendered or rewritten
+			case engine.IsTemplate(path) || rules.isRendered(path):
+				data, err = engine.Render(path, data, t.ctx, partials)
+				if err != nil {
+					if err := collect(path, err); err != nil {
+						return err
+					}
+					continue
+				}
+			case ctx.ModulePlaceholder != "" && rules.isText(path):
+				// For external templates: replace module placeholder in text files
+				data = []byte(strings.ReplaceAll(string(data), ctx.ModulePlaceholder, ctx.Module))
+			}
+			if err := collect(path, writeOutput(outputDir, t.path, path, data, fileMode(info.Mode()), written)); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 }
 
-func resolvePath(path string, ctx Context) string {
-	// Replace template variables in path
-	tmpl, err := template.New("path").Parse(path)
-	if err != nil {
-		return path
+// writeOutput writes the file resolvedPath of the output directory, from
+// the template file src. A file an earlier layer wrote is merged with it or
+// replaced.
+func writeOutput(outputDir, resolvedPath, src string, data []byte, perm fs.FileMode, written map[string]bool) error {
+	outputPath := filepath.Join(outputDir, filepath.FromSlash(resolvedPath))
+	// An earlier layer's symlinks must not lead a write out of the project
+	if err := writer.CheckInside(outputDir, outputPath); err != nil {
+		return &FileError{Path: src, Msg: fmt.Sprintf("cannot write %s: %v", resolvedPath, err)}
+	}
+	// A symlink an earlier layer wrote is replaced, not merged through
+	if info, err := os.Lstat(outputPath); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		written[outputPath] = false
+	}
+	if written[outputPath] {
+		var err error
+		data, err = mergeFile(outputPath, data)
+		if err != nil {
+			return &FileError{Path: src, Msg: fmt.Sprintf("failed to merge into %s: %v", resolvedPath, err)}
+		}
 	}
+	written[outputPath] = true
 
-	var buf strings.Builder
-	if err := tmpl.Execute(&buf, ctx); err != nil {
-		return path
+	return writer.WriteFile(outputPath, data, perm)
+}
+
+// target is an output of a template file: its path and the context it is
+// rendered with.
+type target struct {
+	path string
+	ctx  Context
+}
+
+// expandPath renders the template path p element by element. An element
+// rendering to "" skips the file (for that item, under a ranging element);
+// a ranging element gives one target per item, with ctx.Item set.
+func expandPath(engine Engine, p string, ctx Context) ([]target, error) {
+	targets := []target{{ctx: ctx}}
+	for _, elem := range strings.Split(p, "/") {
+		var next []target
+		for _, t := range targets {
+			rendered, err := engine.RenderElem(elem, t.ctx)
+			if err != nil {
+				fe := templateError(err, map[string]string{elem: p})
+				return nil, &FileError{Path: p, Msg: fmt.Sprintf("path element %q: %s", elem, fe.Msg)}
+			}
+			for _, r := range rendered {
+				if r.Name == "" {
+					continue
+				}
+				if r.Name == "." || r.Name == ".." || strings.ContainsAny(r.Name, `/\`) {
+					return nil, &FileError{Path: p, Msg: fmt.Sprintf("path element %q renders to %q, which is not a file name", elem, r.Name)}
+				}
+				nt := target{path: path.Join(t.path, r.Name), ctx: t.ctx}
+				if r.Ranged {
+					nt.ctx.Item = r.Item
+				}
+				next = append(next, nt)
+			}
+		}
+		targets = next
 	}
+	if engine.IsTemplate(p) {
+		// Remove .tmpl extension
+		for i := range targets {
+			targets[i].path = strings.TrimSuffix(targets[i].path, ".tmpl")
+		}
+	}
+	return targets, nil
+}
 
-	// Remove .tmpl extension
-	result := strings.TrimSuffix(buf.String(), ".tmpl")
+// fileMode is the mode generated files get: executable (0755) when the
+// template file is, else 0644. Only the executable bit is carried over, as
+// embedded templates report every file read-only.
+func fileMode(src fs.FileMode) fs.FileMode {
+	if src.Perm()&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// ReadLinkFS is a file system that can read symbolic links, such as the
+// one DirFS returns. Symlinks of other file systems are rejected.
+type ReadLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+}
+
+// DirFS returns the files under dir, like os.DirFS, with their symlinks.
+func DirFS(dir string) fs.FS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
 
-	return result
+type dirFS struct {
+	fs.FS
+	dir string
 }
 
-func isTextFile(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".go", ".mod", ".yaml", ".yml", ".json", ".toml", ".md", ".txt", ".env", ".gitignore":
-		return true
+func (d dirFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
 	}
-	return false
+	return os.Readlink(filepath.Join(d.dir, filepath.FromSlash(name)))
 }
 
-func renderTemplate(data []byte, outputPath string, ctx Context) error {
-	tmpl, err := template.New("file").Parse(string(data))
+// renderSymlink recreates the symlink at path in the output. Its name is
+// rendered like a file name; its target is kept and must stay inside the
+// template.
+func renderSymlink(fsys fs.FS, path string, engine Engine, rules *Rules, ctx Context, outputDir string, written map[string]bool) error {
+	rl, ok := fsys.(ReadLinkFS)
+	if !ok {
+		return fmt.Errorf("%s: symlinks are not supported in this template", path)
+	}
+	link, err := rl.ReadLink(path)
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return fmt.Errorf("failed to read symlink %s: %w", path, err)
+	}
+	if !writer.LinkInside(path, link) {
+		return fmt.Errorf("%s: symlink to %s points outside the template", path, link)
 	}
 
-	// Execute template to buffer
+	targets := []target{{path: path}}
+	if !rules.isVerbatim(path) {
+		if targets, err = expandPath(engine, path, ctx); err != nil {
+			return err
+		}
+	}
+	for _, t := range targets {
+		outputPath := filepath.Join(outputDir, filepath.FromSlash(t.path))
+		// The target was only checked against the link's path, which must
+		// then be the real one
+		if err := writer.CheckNoSymlinks(outputDir, filepath.Dir(outputPath)); err != nil {
+			return fmt.Errorf("%s: cannot write %s: %w", path, t.path, err)
+		}
+		// A symlink replaces what an earlier layer wrote; it is never merged
+		written[outputPath] = true
+		if err := writer.WriteSymlink(outputPath, link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipped reports whether dir is one of skip (cleaned, slash-separated).
+func skipped(dir string, skip []string) bool {
+	for _, s := range skip {
+		if path.Clean(s) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderString executes s, e.g. a hook command, as a template with ctx.
+func RenderString(s string, ctx Context) (string, error) {
+	tmpl, err := goTemplate{}.parse("string", s)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %q: %w", s, err)
+	}
 	var buf strings.Builder
 	if err := tmpl.Execute(&buf, ctx); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+		return "", fmt.Errorf("failed to execute %q: %w", s, err)
 	}
-
-	// Write to file using writer package
-	return writer.WriteFile(outputPath, []byte(buf.String()))
+	return buf.String(), nil
 }
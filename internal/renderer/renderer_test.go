@@ -0,0 +1,182 @@
+package renderer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	ctx := Context{
+		ProjectName: "app",
+		Values: map[string]any{
+			"db":       false,
+			"services": []any{"api", "worker"},
+			"none":     []any{},
+		},
+	}
+	tests := []struct {
+		name   string
+		engine Engine
+		path   string
+		want   []string
+		items  []any
+	}{
+		{"plain", goTemplate{}, "cmd/main.go", []string{"cmd/main.go"}, nil},
+		{"tmpl extension", goTemplate{}, "cmd/main.go.tmpl", []string{"cmd/main.go"}, nil},
+		{"rendered element", goTemplate{}, "cmd/{{.ProjectName}}/main.go", []string{"cmd/app/main.go"}, nil},
+		{"empty element skips", goTemplate{}, "{{if .Values.db}}db{{end}}/schema.sql", nil, nil},
+		{"range shorthand", goTemplate{}, "services/{{range .Values.services}}/main.go.tmpl", []string{"services/api/main.go", "services/worker/main.go"}, []any{"api", "worker"}},
+		{"range body", goTemplate{}, "{{range .Values.services}}{{.}}-svc{{end}}/Dockerfile", []string{"api-svc/Dockerfile", "worker-svc/Dockerfile"}, []any{"api", "worker"}},
+		{"range file name", goTemplate{}, "cmd/{{range .Values.services}}{{.}}.go{{end}}", []string{"cmd/api.go", "cmd/worker.go"}, []any{"api", "worker"}},
+		{"empty range", goTemplate{}, "{{range .Values.none}}/main.go", nil, nil},
+		{"custom delims", goTemplate{left: "[[", right: "]]"}, "[[.ProjectName]]/{{x}}.go", []string{"app/{{x}}.go"}, nil},
+		{"verbatim", verbatim{}, "{{.ProjectName}}/a.go.tmpl", []string{"{{.ProjectName}}/a.go.tmpl"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := expandPath(tt.engine, tt.path, ctx)
+			if err != nil {
+				t.Fatalf("expandPath(%q): %v", tt.path, err)
+			}
+			var paths []string
+			var items []any
+			for _, tg := range targets {
+				paths = append(paths, tg.path)
+				if tg.ctx.Item != nil {
+					items = append(items, tg.ctx.Item)
+				}
+			}
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("paths = %q, want %q", paths, tt.want)
+			}
+			if !reflect.DeepEqual(items, tt.items) {
+				t.Errorf("items = %v, want %v", items, tt.items)
+			}
+		})
+	}
+}
+
+func TestExpandPathNestedRanges(t *testing.T) {
+	ctx := Context{Values: map[string]any{
+		"services": []any{"api", "worker"},
+		"envs":     []any{"dev", "prod"},
+	}}
+	targets, err := expandPath(goTemplate{}, "{{range .Values.services}}/{{range .Values.envs}}{{.}}.yaml{{end}}", ctx)
+	if err != nil {
+		t.Fatalf("expandPath: %v", err)
+	}
+	var paths []string
+	for _, tg := range targets {
+		paths = append(paths, tg.path)
+	}
+	want := []string{"api/dev.yaml", "api/prod.yaml", "worker/dev.yaml", "worker/prod.yaml"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %q, want %q", paths, want)
+	}
+	// The innermost range sets the item
+	if targets[3].ctx.Item != "prod" {
+		t.Errorf("item = %v, want prod", targets[3].ctx.Item)
+	}
+}
+
+func TestExpandPathErrors(t *testing.T) {
+	ctx := Context{Values: map[string]any{"name": "a/b", "dots": ".."}}
+	tests := []struct {
+		path, want string
+	}{
+		{"{{.Values.name}}/x.go", `renders to "a/b", which is not a file name`},
+		{"x/{{.Values.dots}}/y.go", `renders to "..", which is not a file name`},
+		{"x/{{.Values.missing}}.go", `path element "{{.Values.missing}}.go": `},
+		{"{{.Values.name", `path element "{{.Values.name": `},
+	}
+	for _, tt := range tests {
+		_, err := expandPath(goTemplate{}, tt.path, ctx)
+		var fe *FileError
+		if !errors.As(err, &fe) {
+			t.Errorf("expandPath(%q) = %v, want a *FileError", tt.path, err)
+			continue
+		}
+		if fe.Path != tt.path || !strings.Contains(fe.Msg, tt.want) {
+			t.Errorf("expandPath(%q) = %v, want %s: ...%s...", tt.path, err, tt.path, tt.want)
+		}
+	}
+}
+
+// dirLayer returns a layer of the files (link == "") and symlinks of
+// entries, written to a new directory.
+func dirLayer(t *testing.T, entries ...[3]string) Layer {
+	t.Helper()
+	dir := t.TempDir()
+	for _, e := range entries {
+		p := filepath.Join(dir, filepath.FromSlash(e[0]))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if e[2] != "" {
+			err = os.Symlink(e[2], p)
+		} else {
+			err = os.WriteFile(p, []byte(e[1]), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return Layer{FS: DirFS(dir)}
+}
+
+func TestRenderLayersDoesNotWriteThroughSymlinksOut(t *testing.T) {
+	root := t.TempDir()
+	out := filepath.Join(root, "project")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Each link stays inside its template; chained, l is the parent of
+	// the project
+	layers := []Layer{
+		dirLayer(t, [3]string{"x", "", "."}),
+		dirLayer(t, [3]string{"x/x/l", "", ".."}),
+		dirLayer(t, [3]string{"l/PWNED", "pwned", ""}),
+	}
+
+	if err := RenderLayers(layers, Context{}, out); err == nil {
+		t.Error("RenderLayers succeeded, want an error")
+	}
+	if _, err := os.Stat(filepath.Join(root, "PWNED")); err == nil {
+		t.Error("RenderLayers wrote PWNED outside the output directory")
+	}
+	if _, err := os.Lstat(filepath.Join(out, "l")); err == nil {
+		t.Error("RenderLayers created a symlink below a symlinked directory")
+	}
+}
+
+func TestRenderLayersSymlinksBetweenLayers(t *testing.T) {
+	out := t.TempDir()
+	layers := []Layer{
+		dirLayer(t, [3]string{"doc/a.md", "a\n", ""}, [3]string{"docs", "", "doc"}, [3]string{"README.md", "", "doc/a.md"}),
+		// A file replaces a symlink, and is written through a link that
+		// stays in the project
+		dirLayer(t, [3]string{"README.md", "# App\n", ""}, [3]string{"docs/b.md", "b\n", ""}),
+	}
+
+	if err := RenderLayers(layers, Context{}, out); err != nil {
+		t.Fatalf("RenderLayers: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(out, "doc", "b.md")); err != nil || string(data) != "b\n" {
+		t.Errorf("doc/b.md = %q (%v), want b", data, err)
+	}
+	info, err := os.Lstat(filepath.Join(out, "README.md"))
+	if err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("README.md is still a symlink (%v)", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(out, "README.md")); string(data) != "# App\n" {
+		t.Errorf("README.md = %q, want the later layer's, not merged with doc/a.md", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(out, "doc", "a.md")); string(data) != "a\n" {
+		t.Errorf("doc/a.md = %q, want it untouched", data)
+	}
+}
@@ -0,0 +1,76 @@
+package renderer
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/cosmos-toolkit/cli/internal/loader"
+)
+
+// defaultTextExtensions are the files in which the module placeholder is
+// replaced, besides files.textExtensions.
+var defaultTextExtensions = []string{".go", ".mod", ".yaml", ".yml", ".json", ".toml", ".md", ".txt", ".env", ".gitignore"}
+
+// Rules decide which files of a layer are generated and how, from the
+// files.* settings of its template.yaml.
+type Rules struct {
+	include, exclude, verbatim, render []string
+	textExtensions                     map[string]bool
+}
+
+// NewRules returns the rules of files.
+func NewRules(files loader.FileConfig) *Rules {
+	r := &Rules{
+		include:        files.Include,
+		exclude:        files.Exclude,
+		verbatim:       files.Verbatim,
+		render:         files.Render,
+		textExtensions: make(map[string]bool),
+	}
+	for _, ext := range append(append([]string(nil), defaultTextExtensions...), files.TextExtensions...) {
+		r.textExtensions["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+	return r
+}
+
+// defaultRules apply to layers without rules.
+var defaultRules = NewRules(loader.FileConfig{})
+
+// skipDir reports whether the directory dir is excluded.
+func (r *Rules) skipDir(dir string) bool {
+	return matchAny(r.exclude, dir)
+}
+
+// skipFile reports whether the file at path is not generated.
+func (r *Rules) skipFile(path string) bool {
+	if len(r.include) > 0 && !matchAny(r.include, path) {
+		return true
+	}
+	return matchAny(r.exclude, path)
+}
+
+// isVerbatim reports whether the file at path is copied as it is.
+func (r *Rules) isVerbatim(path string) bool {
+	return matchAny(r.verbatim, path)
+}
+
+// isRendered reports whether the file at path is rendered although the
+// engine does not consider it a template.
+func (r *Rules) isRendered(path string) bool {
+	return matchAny(r.render, path)
+}
+
+// isText reports whether the module placeholder is replaced in the file
+// at path.
+func (r *Rules) isText(path string) bool {
+	return r.textExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+func matchAny(patterns []string, path string) bool {
+	for _, p := range patterns {
+		if loader.MatchGlob(p, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,449 @@
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache kinds, as accepted by CleanCache and reported in CacheEntry.Kind.
+const (
+	KindTemplate = "template"
+	KindPackage  = "package"
+)
+
+// httpCacheDir holds the conditional-request cache of API responses.
+const httpCacheDir = "http"
+
+// metaFile sits next to _repo in each cache and records when the repo was
+// last updated and when each template/package was last used.
+const metaFile = "meta.json"
+
+// CacheDir returns the root of the cosmos cache: $COSMOS_CACHE_DIR, else
+// $XDG_CACHE_HOME/cosmos, else ~/.cache/cosmos.
+func CacheDir() (string, error) {
+	if dir := os.Getenv("COSMOS_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "cosmos"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".cache", "cosmos"), nil
+}
+
+type cacheMeta struct {
+	// Updated is when the repo was last cloned or pulled.
+	Updated time.Time `json:"updated"`
+	// Source is SourceArchive or SourceBundle for caches that are not git
+	// checkouts; Commit is then the commit they were fetched at.
+	Source  string                `json:"source,omitempty"`
+	Commit  string                `json:"commit,omitempty"`
+	Entries map[string]*entryMeta `json:"entries"`
+}
+
+type entryMeta struct {
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+func readMeta(baseCache string) *cacheMeta {
+	m := &cacheMeta{}
+	if data, err := os.ReadFile(filepath.Join(baseCache, metaFile)); err == nil {
+		_ = json.Unmarshal(data, m)
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]*entryMeta)
+	}
+	return m
+}
+
+// updateMeta applies update to the metadata of baseCache under its own lock
+// (<cache>/templates.meta.lock), which is only held for the read-modify-write,
+// so that concurrent processes do not lose each other's changes. Callers may
+// hold the cache lock. It is best effort: metadata only feeds 'cache list'
+// and 'cache prune', so a failure must not break init or pkg.
+func updateMeta(baseCache string, update func(*cacheMeta)) {
+	if err := os.MkdirAll(baseCache, 0755); err != nil {
+		return
+	}
+	unlock, err := acquireLock(baseCache + ".meta.lock")
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	m := readMeta(baseCache)
+	update(m)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return
+	}
+	// Write then rename, so that readers never see a partial file.
+	tmp, err := os.CreateTemp(baseCache, metaFile+".*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(baseCache, metaFile))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func recordUse(baseCache string, names ...string) {
+	now := time.Now()
+	updateMeta(baseCache, func(m *cacheMeta) {
+		for _, name := range names {
+			m.Entries[name] = &entryMeta{LastUsed: now}
+		}
+	})
+}
+
+func recordUpdate(baseCache string) {
+	updateMeta(baseCache, func(m *cacheMeta) { m.Updated = time.Now() })
+}
+
+// CacheEntry describes one template or package present in the local cache.
+type CacheEntry struct {
+	Kind        string    `json:"kind" yaml:"kind"`
+	Name        string    `json:"name" yaml:"name"`
+	Path        string    `json:"path" yaml:"path"`
+	Commit      string    `json:"commit,omitempty" yaml:"commit,omitempty"`
+	Size        int64     `json:"size" yaml:"size"`
+	LastUpdated time.Time `json:"lastUpdated" yaml:"lastUpdated"`
+	LastUsed    time.Time `json:"lastUsed,omitempty" yaml:"lastUsed,omitempty"`
+}
+
+// ListCache returns the templates checked out in the templates cache and
+// the packages in the packages cache, sorted by kind and name.
+func ListCache() ([]CacheEntry, error) {
+	root, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	templates := listCacheKind(filepath.Join(root, templatesCacheDir), "", KindTemplate)
+	packages := listCacheKind(filepath.Join(root, packagesCacheDir), "pkg", KindPackage)
+	return append(templates, packages...), nil
+}
+
+func listCacheKind(baseCache, subdir, kind string) []CacheEntry {
+	repoPath := filepath.Join(baseCache, repoDir)
+	dir := filepath.Join(repoPath, subdir)
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	meta := readMeta(baseCache)
+	git := isGitRepo(repoPath)
+
+	var entries []CacheEntry
+	for _, d := range dirEntries {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, d.Name())
+		e := CacheEntry{
+			Kind:        kind,
+			Name:        d.Name(),
+			Path:        path,
+			Size:        dirSize(path),
+			LastUpdated: meta.Updated,
+		}
+		if em := meta.Entries[d.Name()]; em != nil {
+			e.LastUsed = em.LastUsed
+		}
+		if git {
+			e.Commit, e.LastUpdated = lastCommit(repoPath, filepath.Join(subdir, d.Name()), e.LastUpdated)
+		} else {
+			e.Commit = shortCommit(meta.Commit)
+		}
+		if e.LastUpdated.IsZero() {
+			if info, err := d.Info(); err == nil {
+				e.LastUpdated = info.ModTime()
+			}
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// lastCommit returns the short hash of the last commit touching rel. The
+// update time is kept when known, else the commit time is used.
+func lastCommit(repoPath, rel string, updated time.Time) (string, time.Time) {
+	cmd := exec.Command("git", "log", "-1", "--format=%h %ct", "--", filepath.ToSlash(rel))
+	cmd.Dir = repoPath
+	out, err := cmd.Output()
+	if err != nil {
+		return "", updated
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return "", updated
+	}
+	if updated.IsZero() {
+		if sec, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			updated = time.Unix(sec, 0)
+		}
+	}
+	return fields[0], updated
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// HTTPCacheDir returns where API responses are cached for conditional
+// requests (<cache>/http).
+func HTTPCacheDir() (string, error) {
+	root, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, httpCacheDir), nil
+}
+
+// CleanCache removes the templates cache, the packages cache, or both (and
+// the API response cache) when kind is empty. It returns the removed
+// directories.
+func CleanCache(kind string) ([]string, error) {
+	root, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	switch kind {
+	case "":
+		dirs = []string{filepath.Join(root, templatesCacheDir), filepath.Join(root, packagesCacheDir), filepath.Join(root, httpCacheDir)}
+	case KindTemplate:
+		dirs = []string{filepath.Join(root, templatesCacheDir)}
+	case KindPackage:
+		dirs = []string{filepath.Join(root, packagesCacheDir)}
+	default:
+		return nil, fmt.Errorf("unknown cache kind %q", kind)
+	}
+
+	var removed []string
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		unlock := func() {}
+		if name := filepath.Base(dir); name != httpCacheDir {
+			if unlock, err = LockCache(cacheKindOf(name)); err != nil {
+				return removed, err
+			}
+		}
+		err = os.RemoveAll(dir)
+		unlock()
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", dir, err)
+		}
+		removed = append(removed, dir)
+	}
+	return removed, nil
+}
+
+func cacheKindOf(dir string) string {
+	if dir == packagesCacheDir {
+		return KindPackage
+	}
+	return KindTemplate
+}
+
+// PruneCache drops cache entries not used since before cutoff. Templates are
+// removed from the sparse checkout one by one; the packages cache is checked
+// out as a whole (pkg/), so it is removed only when no package in it was used
+// since cutoff. Entries never used count from their last update. With dryRun
+// nothing is removed. It returns the pruned entries.
+func PruneCache(cutoff time.Time, dryRun bool) ([]CacheEntry, error) {
+	entries, err := ListCache()
+	if err != nil {
+		return nil, err
+	}
+
+	var staleTemplates, keepTemplates []string
+	var pruned []CacheEntry
+	var packages []CacheEntry
+	pkgsInUse := false
+	for _, e := range entries {
+		if e.Kind == KindPackage {
+			packages = append(packages, e)
+			if !isStale(e, cutoff) {
+				pkgsInUse = true
+			}
+			continue
+		}
+		if isStale(e, cutoff) {
+			staleTemplates = append(staleTemplates, e.Name)
+			pruned = append(pruned, e)
+		} else {
+			keepTemplates = append(keepTemplates, e.Name)
+		}
+	}
+	if !pkgsInUse {
+		pruned = append(pruned, packages...)
+	}
+	if dryRun {
+		return pruned, nil
+	}
+
+	if len(staleTemplates) > 0 {
+		if err := pruneTemplates(staleTemplates, keepTemplates); err != nil {
+			return nil, err
+		}
+	}
+	if !pkgsInUse && len(packages) > 0 {
+		if _, err := CleanCache(KindPackage); err != nil {
+			return nil, err
+		}
+	}
+	return pruned, nil
+}
+
+func isStale(e CacheEntry, cutoff time.Time) bool {
+	last := e.LastUsed
+	if last.IsZero() {
+		last = e.LastUpdated
+	}
+	return last.Before(cutoff)
+}
+
+func pruneTemplates(stale, keep []string) error {
+	repoPath, err := TemplatesRepoPath()
+	if err != nil {
+		return err
+	}
+	baseCache := filepath.Dir(repoPath)
+
+	unlock, err := LockCache(KindTemplate)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if isGitRepo(repoPath) {
+		// An empty set keeps only the root files (manifest.yaml, README).
+		args := append([]string{"sparse-checkout", "set"}, keep...)
+		if err := runGit(repoPath, "Pruning templates cache", args...); err != nil {
+			return err
+		}
+	} else {
+		for _, name := range stale {
+			if err := os.RemoveAll(filepath.Join(repoPath, name)); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", name, err)
+			}
+		}
+	}
+
+	updateMeta(baseCache, func(m *cacheMeta) {
+		for _, name := range stale {
+			delete(m.Entries, name)
+		}
+	})
+	return nil
+}
+
+// EntryCommit returns the short hash of the last commit touching a cached
+// template or package (for an archive cache, the commit it was fetched at),
+// or "" when unknown.
+func EntryCommit(kind, name string) string {
+	repoPath, rel, err := entryLocation(kind, name)
+	if err != nil {
+		return ""
+	}
+	if !isGitRepo(repoPath) {
+		return shortCommit(readMeta(filepath.Dir(repoPath)).Commit)
+	}
+	commit, _ := lastCommit(repoPath, rel, time.Time{})
+	return commit
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+// RecordImported notes that entries of kind were imported (e.g. from a
+// bundle) with content as of at, so 'cache list' shows a meaningful date.
+func RecordImported(kind string, at time.Time, names ...string) {
+	repoPath, _, err := entryLocation(kind, "")
+	if err != nil {
+		return
+	}
+	baseCache := filepath.Dir(repoPath)
+	git := isGitRepo(repoPath)
+	updateMeta(baseCache, func(m *cacheMeta) {
+		if m.Updated.IsZero() || at.After(m.Updated) {
+			m.Updated = at
+		}
+		if !git && m.Source == "" {
+			m.Source = SourceBundle
+		}
+	})
+	recordUse(baseCache, names...)
+}
+
+// EntryPath returns where a template or package lives in the cache,
+// whether or not it exists.
+func EntryPath(kind, name string) (string, error) {
+	repoPath, rel, err := entryLocation(kind, name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(repoPath, rel), nil
+}
+
+func entryLocation(kind, name string) (repoPath, rel string, err error) {
+	switch kind {
+	case KindTemplate:
+		repoPath, err = TemplatesRepoPath()
+		rel = name
+	case KindPackage:
+		repoPath, err = PackagesRepoPath()
+		rel = filepath.Join("pkg", name)
+	default:
+		err = fmt.Errorf("unknown cache kind %q", kind)
+	}
+	return repoPath, rel, err
+}
+
+// RepoPath returns the _repo directory of the cache of kind.
+func RepoPath(kind string) (string, error) {
+	repoPath, _, err := entryLocation(kind, "")
+	return repoPath, err
+}
+
+// IsGitCheckout reports whether the cache of kind is a git checkout (as
+// opposed to missing, or seeded from a bundle).
+func IsGitCheckout(kind string) bool {
+	repoPath, _, err := entryLocation(kind, "")
+	return err == nil && isGitRepo(repoPath)
+}
@@ -0,0 +1,131 @@
+package resolver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cosmos-toolkit/cli/internal/registry"
+	"gopkg.in/yaml.v3"
+)
+
+// CachedTemplates returns the template names known to the local cache: the
+// directories checked out in the templates repo plus the entries of its
+// manifest.yaml (root files are always present in a cone sparse checkout).
+// It never touches the network; a missing cache yields an empty list.
+func CachedTemplates() []string {
+	repoPath, err := TemplatesRepoPath()
+	if err != nil {
+		return nil
+	}
+	return mergeNames(checkedOut(repoPath), mapKeys(readManifest(filepath.Join(repoPath, "manifest.yaml"), "templates")))
+}
+
+// CachedPackages is like CachedTemplates for the packages repo (pkg/ subdir).
+func CachedPackages() []string {
+	repoPath, err := PackagesRepoPath()
+	if err != nil {
+		return nil
+	}
+	return mergeNames(checkedOut(filepath.Join(repoPath, "pkg")), mapKeys(readManifest(filepath.Join(repoPath, "manifest.yaml"), "packages")))
+}
+
+// CachedTemplatesWithInfo lists the templates actually checked out in the
+// cache (usable offline), with descriptions from the cached manifest.yaml.
+func CachedTemplatesWithInfo() []registry.TemplateInfo {
+	repoPath, err := TemplatesRepoPath()
+	if err != nil {
+		return []registry.TemplateInfo{}
+	}
+	descriptions := readManifest(filepath.Join(repoPath, "manifest.yaml"), "templates")
+	names := checkedOut(repoPath)
+	templates := make([]registry.TemplateInfo, 0, len(names))
+	for _, name := range names {
+		templates = append(templates, registry.NewTemplateInfo(name, descriptions[name]))
+	}
+	return templates
+}
+
+// CachedPackagesWithInfo is like CachedTemplatesWithInfo for packages.
+func CachedPackagesWithInfo() []registry.PackageInfo {
+	repoPath, err := PackagesRepoPath()
+	if err != nil {
+		return []registry.PackageInfo{}
+	}
+	descriptions := readManifest(filepath.Join(repoPath, "manifest.yaml"), "packages")
+	names := checkedOut(filepath.Join(repoPath, "pkg"))
+	pkgs := make([]registry.PackageInfo, 0, len(names))
+	for _, name := range names {
+		pkgs = append(pkgs, registry.NewPackageInfo(name, descriptions[name]))
+	}
+	return pkgs
+}
+
+// CachedPackagesManifest returns manifest.yaml from the packages cache.
+func CachedPackagesManifest() ([]byte, error) {
+	repoPath, err := PackagesRepoPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(repoPath, "manifest.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("packages manifest %w: %v", ErrNotCached, err)
+	}
+	return data, nil
+}
+
+// checkedOut returns the non-hidden directories of dir, sorted.
+func checkedOut(dir string) []string {
+	var names []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readManifest returns name -> description for the entries under key
+// (templates or packages) of a manifest.yaml.
+func readManifest(path, key string) map[string]string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var m map[string]map[string]struct {
+		Description string `yaml:"description"`
+	}
+	if yaml.Unmarshal(data, &m) != nil {
+		return nil
+	}
+	out := make(map[string]string, len(m[key]))
+	for name, v := range m[key] {
+		out[name] = v.Description
+	}
+	return out
+}
+
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func mergeNames(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, n := range append(a, b...) {
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
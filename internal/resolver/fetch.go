@@ -0,0 +1,299 @@
+package resolver
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cosmos-toolkit/cli/internal/provider"
+	"github.com/cosmos-toolkit/cli/internal/registry"
+	"github.com/cosmos-toolkit/cli/internal/writer"
+)
+
+// Fetchers, as accepted by SetFetcher (config "fetcher").
+const (
+	// FetcherAuto uses git when it is on PATH, else the archive fetcher.
+	FetcherAuto = "auto"
+	// FetcherGit uses a sparse git checkout (needs git 2.26+).
+	FetcherGit = "git"
+	// FetcherArchive downloads tarballs from the GitHub API; no git needed.
+	FetcherArchive = "archive"
+)
+
+// Cache sources, recorded in meta.json and returned by CacheSource.
+const (
+	SourceGit     = "git"
+	SourceArchive = "archive"
+	SourceBundle  = "bundle"
+)
+
+var fetcher = FetcherAuto
+
+// SetFetcher selects how caches are populated: FetcherAuto, FetcherGit or
+// FetcherArchive. An existing cache keeps the fetcher that created it until
+// it is cleaned.
+func SetFetcher(name string) {
+	fetcher = name
+}
+
+// FetcherInUse returns the fetcher new caches are created with.
+func FetcherInUse() string {
+	switch fetcher {
+	case FetcherGit, FetcherArchive:
+		return fetcher
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return FetcherArchive
+	}
+	return FetcherGit
+}
+
+func useGit() bool {
+	return FetcherInUse() == FetcherGit
+}
+
+// CacheSource reports how the cache of kind was populated (SourceGit,
+// SourceArchive or SourceBundle), or "" when it does not exist.
+func CacheSource(kind string) string {
+	repoPath, err := RepoPath(kind)
+	if err != nil {
+		return ""
+	}
+	if isGitRepo(repoPath) {
+		return SourceGit
+	}
+	if _, err := os.Stat(repoPath); err != nil {
+		return ""
+	}
+	if src := readMeta(filepath.Dir(repoPath)).Source; src != "" {
+		return src
+	}
+	return SourceBundle
+}
+
+// source returns the registry provider of the cache of kind.
+func source(kind string) provider.Provider {
+	if kind == KindPackage {
+		return registry.Get(registry.Packages)
+	}
+	return registry.Get(registry.Templates)
+}
+
+// sourceRef returns the branch or tag configured for the cache of kind.
+func sourceRef(kind string) string {
+	if kind == KindPackage {
+		return registry.Describe(registry.Packages).Ref
+	}
+	return registry.Describe(registry.Templates).Ref
+}
+
+// fetchArchive downloads the archive of the repository of kind and extracts
+// into repoPath the files at the repository root (manifest.yaml, README) and
+// the directories in dirs, which replace any existing copy. The commit of
+// the archive is recorded in meta.json.
+func fetchArchive(kind, repoPath, label string, dirs ...string) error {
+	src, ok := source(kind).(provider.Archiver)
+	if !ok && isPlainCache(repoPath) {
+		return plainCacheError(kind, repoPath)
+	}
+	if !ok {
+		return fmt.Errorf("the %s registry cannot download archives; install git or use another registry type", kind)
+	}
+
+	stop := startProgress(label)
+	defer stop()
+
+	body, err := src.Tarball()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(repoPath), ".fetch-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	commit, err := extractArchive(body, staging, dirs)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", source(kind).CloneURL(), err)
+	}
+
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool)
+	for _, e := range entries {
+		dst := filepath.Join(repoPath, e.Name())
+		if err := os.RemoveAll(dst); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", dst, err)
+		}
+		if err := os.Rename(filepath.Join(staging, e.Name()), dst); err != nil {
+			return fmt.Errorf("failed to install %s: %w", dst, err)
+		}
+		found[e.Name()] = true
+	}
+	// A directory gone upstream disappears from the cache too.
+	for _, d := range dirs {
+		if !found[d] {
+			os.RemoveAll(filepath.Join(repoPath, d))
+		}
+	}
+
+	baseCache := filepath.Dir(repoPath)
+	updateMeta(baseCache, func(m *cacheMeta) {
+		if m.Source != SourceBundle {
+			// A bundle cache stays one: 'cosmos update' must not drop what
+			// was imported because it is not upstream.
+			m.Source = SourceArchive
+		}
+		m.Commit = commit
+	})
+	recordUpdate(baseCache)
+	return nil
+}
+
+// extractArchive unpacks a GitHub tarball into dir, stripping the leading
+// "<owner>-<repo>-<sha>/" component and keeping only root files and dirs.
+// It returns the commit recorded in the pax global header.
+func extractArchive(r io.Reader, dir string, dirs []string) (commit string, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return "", err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return commit, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			commit = hdr.PAXRecords["comment"]
+			continue
+		}
+		_, rel, ok := strings.Cut(strings.TrimSuffix(hdr.Name, "/"), "/")
+		if !ok || rel == "" {
+			continue
+		}
+		rel = path.Clean(rel)
+		if !wantArchivePath(rel, hdr.Typeflag, dirs) {
+			continue
+		}
+		if rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+			return "", fmt.Errorf("invalid path %q in archive", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		// Links are checked on their own; an entry written through a link
+		// an earlier entry created could land anywhere
+		if err := writer.CheckNoSymlinks(dir, target); err != nil {
+			return "", fmt.Errorf("invalid path %q in archive: %w", hdr.Name, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return "", err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return "", err
+			}
+		case tar.TypeReg:
+			if err := writeArchiveFile(target, tr, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return "", err
+			}
+		case tar.TypeSymlink:
+			// Like a git checkout, keep links that stay inside the repo.
+			if !writer.LinkInside(rel, hdr.Linkname) {
+				return "", fmt.Errorf("symlink %q points outside the repository", rel)
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// wantArchivePath keeps regular files at the root and everything under dirs.
+func wantArchivePath(rel string, typ byte, dirs []string) bool {
+	if !strings.Contains(rel, "/") && typ != tar.TypeDir {
+		return true
+	}
+	for _, d := range dirs {
+		if rel == d || strings.HasPrefix(rel, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func writeArchiveFile(target string, r io.Reader, mode os.FileMode) error {
+	if mode == 0 {
+		mode = 0644
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// pullRepo brings the cache of kind up to date: git pull for a git checkout,
+// a new download of the cached directories for an archive cache. Caches
+// imported from a bundle have no remote; errNoRemote is returned for them.
+func pullRepo(kind, repoPath, label string) error {
+	if isGitRepo(repoPath) {
+		return runGit(repoPath, label, "pull")
+	}
+	if readMeta(filepath.Dir(repoPath)).Source != SourceArchive {
+		return errNoRemote
+	}
+	dirs := []string{"pkg"}
+	if kind == KindTemplate {
+		dirs = nil
+		for _, e := range listCacheKind(filepath.Dir(repoPath), "", KindTemplate) {
+			dirs = append(dirs, e.Name)
+		}
+	}
+	return fetchArchive(kind, repoPath, label, dirs...)
+}
+
+// isPlainCache reports whether repoPath is a cache that is not a git
+// checkout: imported from a bundle or downloaded as an archive. Git must not
+// clone over it.
+func isPlainCache(repoPath string) bool {
+	_, err := os.Stat(repoPath)
+	return err == nil && !isGitRepo(repoPath)
+}
+
+// plainCacheError explains that the plain cache at repoPath cannot be
+// extended by the registry of kind.
+func plainCacheError(kind, repoPath string) error {
+	return fmt.Errorf("the %s cache at %s is not a git checkout (imported from a bundle or downloaded as an archive) and the %s registry cannot add to it; import a bundle with what you need, or run 'cosmos cache clean %ss' to fetch from the registry", kind, repoPath, kind, kind)
+}
+
+// errNoRemote marks a cache that cannot be pulled (imported from a bundle).
+var errNoRemote = errors.New("cache has no remote")
+
+// canPull reports whether pullRepo can update the cache at repoPath.
+func canPull(repoPath string) bool {
+	return isGitRepo(repoPath) || readMeta(filepath.Dir(repoPath)).Source == SourceArchive
+}
@@ -0,0 +1,102 @@
+package resolver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testArchive returns a repository tarball, as the GitHub API serves it,
+// with the commit and the files (link == "") and symlinks of entries under
+// its top directory.
+func testArchive(t *testing.T, commit string, entries ...[3]string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": commit}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		name, data, link := "acme-templates-"+commit+"/"+e[0], e[1], e[2]
+		hdr := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(data))}
+		if link != "" {
+			hdr = &tar.Header{Typeflag: tar.TypeSymlink, Name: name, Linkname: link, Mode: 0777}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractArchive(t *testing.T) {
+	dir := t.TempDir()
+	a := testArchive(t, "abc123",
+		[3]string{"manifest.yaml", "templates: {}\n", ""},
+		[3]string{"api/template.yaml", "name: api\n", ""},
+		[3]string{"api/README.md", "", "docs/index.md"},
+		[3]string{"web/template.yaml", "name: web\n", ""},
+	)
+
+	commit, err := extractArchive(a, dir, []string{"api"})
+	if err != nil {
+		t.Fatalf("extractArchive: %v", err)
+	}
+	if commit != "abc123" {
+		t.Errorf("commit = %q, want abc123", commit)
+	}
+	for _, p := range []string{"manifest.yaml", "api/template.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(p))); err != nil {
+			t.Errorf("%s was not extracted: %v", p, err)
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(dir, "api", "README.md")); err != nil || link != "docs/index.md" {
+		t.Errorf("api/README.md links to %q (%v), want docs/index.md", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "web")); !os.IsNotExist(err) {
+		t.Error("web was extracted, though only api was asked for")
+	}
+}
+
+func TestExtractArchiveRejectsSymlinkEscapes(t *testing.T) {
+	tests := map[string][][3]string{
+		"link out of the repo": {
+			{"api/up", "", "../../.."},
+		},
+		// Each link stays inside on its own; chained, api/l is the parent
+		// of the cache directory
+		"write through links": {
+			{"api/x", "", "."},
+			{"api/x/x/l", "", "../.."},
+			{"api/l/PWNED", "pwned", ""},
+		},
+	}
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "cache")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := extractArchive(testArchive(t, "abc123", entries...), dir, []string{"api"}); err == nil {
+				t.Error("extractArchive succeeded, want an error")
+			}
+			if _, err := os.Stat(filepath.Join(root, "PWNED")); err == nil {
+				t.Error("extractArchive wrote PWNED outside the cache directory")
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package resolver
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos-toolkit/cli/internal/provider"
+	"github.com/cosmos-toolkit/cli/internal/registry"
+)
+
+// GitCause classifies why a git command failed, from its stderr.
+type GitCause string
+
+const (
+	CauseAuth        GitCause = "auth"
+	CauseNetwork     GitCause = "network"
+	CauseRefNotFound GitCause = "ref not found"
+	CauseNotRepo     GitCause = "not a git repo"
+	CauseUnknown     GitCause = "unknown"
+)
+
+// causePatterns are matched, lower-cased, against git's stderr in order.
+var causePatterns = []struct {
+	cause    GitCause
+	patterns []string
+}{
+	{CauseNotRepo, []string{"not a git repository"}},
+	{CauseAuth, []string{
+		"authentication failed", "could not read username", "could not read password",
+		"terminal prompts disabled", "permission denied (publickey", "repository not found",
+		"host key verification failed",
+		"returned error: 401", "returned error: 403", "access denied",
+	}},
+	{CauseRefNotFound, []string{
+		"couldn't find remote ref", "remote branch", "not found in upstream",
+		"no such ref", "unknown revision", "invalid reference", "did not match any",
+	}},
+	{CauseNetwork, []string{
+		"could not resolve host", "failed to connect", "connection timed out", "connection refused",
+		"network is unreachable", "unable to access", "could not read from remote repository",
+		"early eof", "rpc failed", "ssl certificate", "proxy",
+	}},
+}
+
+var causeHints = map[GitCause]string{
+	CauseAuth:        "authentication failed or access denied",
+	CauseNetwork:     "network error; check your connection and proxy settings, or use --offline",
+	CauseRefNotFound: "branch or ref not found in the remote repository",
+	CauseNotRepo:     "cache is not a git repository; run 'cosmos cache clean' and retry",
+}
+
+// GitError is returned when a git command fails. Stderr holds the lines of
+// git's output that explain the failure.
+type GitError struct {
+	Args   []string
+	Cause  GitCause
+	Stderr []string
+	Err    error
+}
+
+// Error hides tokens and URL passwords that git may echo.
+func (e *GitError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "git %s: %v", e.Args[0], e.Err)
+	if hint := causeHints[e.Cause]; hint != "" {
+		fmt.Fprintf(&b, " (%s)", hint)
+	}
+	for _, line := range e.Stderr {
+		b.WriteString("\n    ")
+		b.WriteString(line)
+	}
+	return provider.Redact(b.String())
+}
+
+func (e *GitError) Unwrap() error { return e.Err }
+
+// maxStderrLines bounds how much of git's output ends up in an error.
+const maxStderrLines = 5
+
+// runGit runs git in dir with its output captured, showing label next to a
+// spinner while it runs. Prompts are disabled so a missing credential fails
+// instead of hanging behind the spinner; registry tokens are passed in the
+// environment (see registry.GitEnv).
+func runGit(dir, label string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = gitEnv()
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	stop := startProgress(label)
+	err := cmd.Run()
+	stop()
+	if err != nil {
+		return newGitError(args, out.String(), err)
+	}
+	return nil
+}
+
+// gitEnv is the environment of git commands: no terminal prompts, ssh in
+// batch mode (unless the user configured ssh for git) and the registry
+// tokens.
+func gitEnv() []string {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if os.Getenv("GIT_SSH_COMMAND") == "" && os.Getenv("GIT_SSH") == "" {
+		env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	return append(env, registry.GitEnv()...)
+}
+
+func newGitError(args []string, output string, err error) *GitError {
+	lines := relevantLines(output)
+	return &GitError{Args: args, Cause: classify(output), Stderr: lines, Err: err}
+}
+
+func classify(output string) GitCause {
+	lower := strings.ToLower(output)
+	for _, c := range causePatterns {
+		for _, p := range c.patterns {
+			if strings.Contains(lower, p) {
+				return c.cause
+			}
+		}
+	}
+	return CauseUnknown
+}
+
+// relevantLines keeps the fatal/error lines of git's output, or the last
+// non-empty lines when there are none. Progress lines are dropped.
+func relevantLines(output string) []string {
+	var all, errs []string
+	for _, line := range strings.Split(output, "\n") {
+		// Progress output rewrites the line with carriage returns.
+		if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+			line = line[i+1:]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		all = append(all, line)
+		if strings.HasPrefix(line, "fatal:") || strings.HasPrefix(line, "error:") {
+			errs = append(errs, line)
+		}
+	}
+	if len(errs) == 0 {
+		errs = all
+	}
+	if len(errs) > maxStderrLines {
+		errs = errs[len(errs)-maxStderrLines:]
+	}
+	return errs
+}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// startProgress shows a spinner with label on the output writer when it is
+// a terminal, and returns a function that clears it. Elsewhere (pipes, CI
+// logs) nothing is printed.
+func startProgress(label string) (stop func()) {
+	if !isTerminal(output) {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			fmt.Fprintf(output, "\r%s %s", spinnerFrames[i%len(spinnerFrames)], label)
+			select {
+			case <-done:
+				fmt.Fprint(output, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
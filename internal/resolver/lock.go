@@ -0,0 +1,111 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when a cache lock could not be acquired in time.
+var ErrLocked = errors.New("cache is locked")
+
+const (
+	// lockTimeout bounds how long a process waits for another one to finish
+	// cloning or pulling the same cache.
+	lockTimeout  = 5 * time.Minute
+	lockInterval = 200 * time.Millisecond
+)
+
+// LockCache takes the exclusive lock of the cache of kind, waiting up to
+// lockTimeout while another cosmos process holds it. The returned function
+// releases the lock. Lock files live in the cache root (templates.lock,
+// packages.lock) so that removing a cache does not remove its lock.
+func LockCache(kind string) (unlock func(), err error) {
+	root, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	var name string
+	switch kind {
+	case KindTemplate:
+		name = templatesCacheDir
+	case KindPackage:
+		name = packagesCacheDir
+	default:
+		return nil, fmt.Errorf("unknown cache kind %q", kind)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return acquireLock(filepath.Join(root, name+".lock"))
+}
+
+// acquireLock takes an advisory lock (flock, or LockFileEx on Windows) on
+// the file at path, which is created when missing and never removed. The
+// kernel releases the lock when its holder exits, however it exits, so a
+// lock is never stale, and this holds across PID namespaces (containers
+// sharing a cache). The holder's pid is written to the file only for the
+// message of the processes waiting for it.
+func acquireLock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock %s: %w", path, err)
+	}
+	deadline := time.Now().Add(lockTimeout)
+	waiting := -1
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			break
+		}
+		pid := lockHolder(f)
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w: timed out after %s waiting for %s held by %s", ErrLocked, lockTimeout, path, describePid(pid))
+		}
+		if pid != waiting {
+			fmt.Fprintf(os.Stderr, "waiting for lock held by %s (%s)...\n", describePid(pid), path)
+			waiting = pid
+		}
+		time.Sleep(lockInterval)
+	}
+
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return func() {
+		_ = f.Truncate(0)
+		_ = unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// lockHolder returns the pid written in the lock file, or 0 when unknown
+// (the holder has not written it yet).
+func lockHolder(f *os.File) int {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 32))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return pid
+}
+
+func describePid(pid int) string {
+	if pid == 0 {
+		return "another cosmos process"
+	}
+	return fmt.Sprintf("pid %d", pid)
+}
@@ -0,0 +1,15 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || aix || solaris || windows)
+
+package resolver
+
+import "os"
+
+// tryLockFile always succeeds: these platforms (js, wasip1, plan9, z/OS)
+// have no advisory lock cosmos uses, and it runs there as a single process.
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
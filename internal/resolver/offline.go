@@ -0,0 +1,24 @@
+package resolver
+
+import "errors"
+
+var (
+	// ErrNotCached is returned in offline mode when a template or package
+	// is not present in the local cache.
+	ErrNotCached = errors.New("is not cached")
+	// ErrOffline is returned by operations that need the network.
+	ErrOffline = errors.New("is not available in offline mode")
+)
+
+// offline forbids network git operations; only the local caches are used.
+var offline bool
+
+// SetOffline enables or disables offline mode (--offline, COSMOS_OFFLINE=1).
+func SetOffline(v bool) {
+	offline = v
+}
+
+// IsOffline reports whether offline mode is enabled.
+func IsOffline() bool {
+	return offline
+}
@@ -3,100 +3,125 @@ package resolver
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 )
 
 const (
-	packagesRepoURL  = "https://github.com/cosmos-toolkit/packages"
-	packagesCacheDir = ".cache/cosmos/packages"
+	packagesCacheDir = "packages"
 	packagesRepoDir  = "_repo"
 )
 
-// ResolvePackagesRepo clones or updates the packages repo with sparse checkout
-// for the "pkg" directory and returns the path to the repo root.
+// ResolvePackagesRepo clones the packages repo with sparse checkout for the
+// "pkg" directory, or pulls it when stale (see SetRefresh), and returns the
+// path to the repo root.
 func ResolvePackagesRepo() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	root, err := CacheDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return "", err
 	}
 
-	baseCache := filepath.Join(homeDir, packagesCacheDir)
+	baseCache := filepath.Join(root, packagesCacheDir)
 	repoPath := filepath.Join(baseCache, packagesRepoDir)
 	pkgPath := filepath.Join(repoPath, "pkg")
 
 	if _, err := os.Stat(pkgPath); err == nil {
-		// Already have pkg/; try to pull
-		if isGitRepo(repoPath) {
-			pullCmd := exec.Command("git", "pull")
-			pullCmd.Dir = repoPath
-			pullCmd.Stdout = os.Stdout
-			pullCmd.Stderr = os.Stderr
-			_ = pullCmd.Run()
+		// Already have pkg/; pull it first when stale
+		if err := refreshIfStale(KindPackage); err != nil {
+			return "", err
 		}
 		return repoPath, nil
 	}
 
+	if offline {
+		return "", fmt.Errorf("packages %w in %s (offline mode); run 'cosmos pkg' once without --offline to fetch them, or import a bundle", ErrNotCached, repoPath)
+	}
+
+	unlock, err := LockCache(KindPackage)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	// Another process may have fetched it while we waited for the lock.
+	if _, err := os.Stat(pkgPath); err == nil {
+		return repoPath, nil
+	}
+
 	if err := os.MkdirAll(baseCache, 0755); err != nil {
 		return "", fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
+	if !isGitRepo(repoPath) && (!useGit() || isPlainCache(repoPath)) {
+		if err := fetchArchive(KindPackage, repoPath, "Fetching packages", "pkg"); err != nil {
+			return "", fmt.Errorf("failed to fetch packages: %w", err)
+		}
+		return repoPath, nil
+	}
+
 	if isGitRepo(repoPath) {
-		if err := runInDir(repoPath, "git", "sparse-checkout", "add", "pkg"); err != nil {
+		if err := runGit(repoPath, "Fetching packages", "sparse-checkout", "add", "pkg"); err != nil {
 			return "", fmt.Errorf("failed to add pkg to sparse checkout: %w", err)
 		}
-		if err := runInDir(repoPath, "git", "pull"); err != nil {
-			return "", fmt.Errorf("failed to pull: %w", err)
+		if refresh == RefreshNever {
+			return repoPath, nil
+		}
+		if err := runGit(repoPath, "Fetching packages", "pull"); err != nil {
+			return "", fmt.Errorf("failed to pull packages repo: %w", err)
 		}
+		recordUpdate(baseCache)
 		return repoPath, nil
 	}
 
-	os.RemoveAll(repoPath)
-	cloneCmd := exec.Command("git", "clone",
-		"--depth", "1",
-		"--filter=blob:none",
-		"--sparse",
-		packagesRepoURL,
-		repoPath,
-	)
-	cloneCmd.Stdout = os.Stdout
-	cloneCmd.Stderr = os.Stderr
-	if err := cloneCmd.Run(); err != nil {
+	if err := runGit("", "Fetching packages", cloneArgs(KindPackage, repoPath)...); err != nil {
 		return "", fmt.Errorf("failed to clone packages repo: %w", err)
 	}
 
-	if err := runInDir(repoPath, "git", "sparse-checkout", "set", "pkg"); err != nil {
+	if err := runGit(repoPath, "Fetching packages", "sparse-checkout", "set", "pkg"); err != nil {
 		return "", fmt.Errorf("failed to sparse-checkout pkg: %w", err)
 	}
 
+	recordUpdate(baseCache)
 	return repoPath, nil
 }
 
-// PackagesRepoPath returns the path to the cached packages repo (~/.cache/cosmos/packages/_repo).
+// PackagesRepoPath returns the path to the cached packages repo (<cache>/packages/_repo).
 func PackagesRepoPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	root, err := CacheDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(homeDir, packagesCacheDir, packagesRepoDir), nil
+	return filepath.Join(root, packagesCacheDir, packagesRepoDir), nil
 }
 
-// PullPackagesRepo runs git pull in the packages cache if the repo exists.
-// It returns (true, nil) when pull ran, (false, nil) when no cache exists, or (_, err) on failure.
+// RecordPackagesUsed marks packages as used now, for 'cosmos cache prune'.
+func RecordPackagesUsed(names ...string) {
+	root, err := CacheDir()
+	if err != nil {
+		return
+	}
+	recordUse(filepath.Join(root, packagesCacheDir), names...)
+}
+
+// PullPackagesRepo updates the packages cache like PullTemplatesRepo.
 func PullPackagesRepo() (updated bool, err error) {
 	repoPath, err := PackagesRepoPath()
 	if err != nil {
 		return false, err
 	}
-	if !isGitRepo(repoPath) {
+	if !canPull(repoPath) {
 		return false, nil
 	}
-	pullCmd := exec.Command("git", "pull")
-	pullCmd.Dir = repoPath
-	pullCmd.Stdout = os.Stdout
-	pullCmd.Stderr = os.Stderr
-	if err := pullCmd.Run(); err != nil {
-		return false, fmt.Errorf("packages: git pull: %w", err)
+	if offline {
+		return false, fmt.Errorf("packages: git pull %w", ErrOffline)
+	}
+	unlock, err := LockCache(KindPackage)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if err := pullRepo(KindPackage, repoPath, "Updating packages cache"); err != nil {
+		return false, fmt.Errorf("packages: %w", err)
 	}
+	recordUpdate(filepath.Dir(repoPath))
 	return true, nil
 }
@@ -0,0 +1,97 @@
+package resolver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cosmos-toolkit/cli/internal/provider"
+)
+
+// RefreshPolicy decides when a cached repo is pulled before use.
+type RefreshPolicy int
+
+const (
+	// RefreshAuto pulls when the cache is older than the TTL.
+	RefreshAuto RefreshPolicy = iota
+	// RefreshAlways pulls on first use in this process (--refresh).
+	RefreshAlways
+	// RefreshNever uses the cache as is (--no-refresh).
+	RefreshNever
+)
+
+// DefaultCacheTTL is how long a cache is used before it is pulled again.
+const DefaultCacheTTL = 24 * time.Hour
+
+var (
+	refresh  = RefreshAuto
+	cacheTTL = DefaultCacheTTL
+	// refreshed records the caches already pulled by this process, so that
+	// --refresh does not pull once per package.
+	refreshed = make(map[string]bool)
+)
+
+// SetRefresh sets the refresh policy.
+func SetRefresh(p RefreshPolicy) {
+	refresh = p
+}
+
+// SetCacheTTL sets the age after which a cache is considered stale. Zero
+// means every use pulls.
+func SetCacheTTL(d time.Duration) {
+	cacheTTL = d
+}
+
+// needsRefresh reports whether the cache at baseCache should be pulled
+// before use. The age comes from the last update recorded in meta.json.
+func needsRefresh(baseCache string) bool {
+	if offline || refreshed[baseCache] {
+		return false
+	}
+	switch refresh {
+	case RefreshAlways:
+		return true
+	case RefreshNever:
+		return false
+	}
+	m := readMeta(baseCache)
+	return m.Updated.IsZero() || time.Since(m.Updated) >= cacheTTL
+}
+
+// refreshIfStale pulls the repo of kind (see pullRepo) when needsRefresh
+// says so. Caches imported from a bundle have no remote and are left alone. A failed pull
+// is fatal only with --refresh; otherwise the cached copy is used and a
+// warning is printed.
+func refreshIfStale(kind string) error {
+	repoPath, err := RepoPath(kind)
+	if err != nil {
+		return err
+	}
+	baseCache := filepath.Dir(repoPath)
+	if !canPull(repoPath) || !needsRefresh(baseCache) {
+		return nil
+	}
+
+	unlock, err := LockCache(kind)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// Another process may have pulled it while we waited for the lock.
+	if !needsRefresh(baseCache) {
+		return nil
+	}
+
+	if err := pullRepo(kind, repoPath, fmt.Sprintf("Updating %s cache", kind)); err != nil {
+		if refresh == RefreshAlways {
+			return fmt.Errorf("failed to refresh %s cache: %w", kind, err)
+		}
+		fmt.Fprintf(os.Stderr, "warning: could not refresh %s cache, using cached copy: %s\n", kind, provider.Redact(err.Error()))
+		refreshed[baseCache] = true
+		return nil
+	}
+	refreshed[baseCache] = true
+	recordUpdate(baseCache)
+	return nil
+}
@@ -2,30 +2,58 @@ package resolver
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 )
 
 const (
-	repoURL  = "https://github.com/cosmos-toolkit/templates"
-	cacheDir = ".cache/cosmos/templates"
-	repoDir  = "_repo"
+	templatesCacheDir = "templates"
+	repoDir           = "_repo"
 )
 
+// output receives progress (spinners) while git runs; git's own output is
+// captured and only shown, in the error, when it fails.
+var output io.Writer = os.Stdout
+
+// SetOutput redirects progress, e.g. to stderr when the CLI prints json.
+func SetOutput(w io.Writer) {
+	output = w
+}
+
 func Resolve(templateName string) (string, error) {
-	homeDir, err := os.UserHomeDir()
+	root, err := CacheDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return "", err
 	}
 
-	baseCache := filepath.Join(homeDir, cacheDir)
+	baseCache := filepath.Join(root, templatesCacheDir)
 	repoPath := filepath.Join(baseCache, repoDir)
 	templatePath := filepath.Join(repoPath, templateName)
 	templateYAML := filepath.Join(templatePath, "template.yaml")
 
-	// Already in cache with valid template.yaml
+	// Already in cache with valid template.yaml; pull it first when stale
+	if _, err := os.Stat(templateYAML); err == nil {
+		if err := refreshIfStale(KindTemplate); err != nil {
+			return "", err
+		}
+		recordUse(baseCache, templateName)
+		return templatePath, nil
+	}
+
+	if offline {
+		return "", fmt.Errorf("template %q %w in %s (offline mode); run once without --offline to fetch it, or import a bundle", templateName, ErrNotCached, repoPath)
+	}
+
+	unlock, err := LockCache(KindTemplate)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	// Another process may have fetched it while we waited for the lock.
 	if _, err := os.Stat(templateYAML); err == nil {
+		recordUse(baseCache, templateName)
 		return templatePath, nil
 	}
 
@@ -34,18 +62,29 @@ func Resolve(templateName string) (string, error) {
 		return "", fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	repoExists := isGitRepo(repoPath)
-
-	if repoExists {
+	switch {
+	case isGitRepo(repoPath):
 		// Repo exists: add this template to sparse checkout and pull
 		if err := addTemplateToSparseCheckout(repoPath, templateName); err != nil {
-			return "", fmt.Errorf("failed to add template to sparse checkout: %w", err)
+			return "", fmt.Errorf("failed to fetch template: %w", err)
 		}
-	} else {
+	case useGit() && !isPlainCache(repoPath):
 		// Fresh clone with sparse checkout for this template only
 		if err := cloneWithSparseCheckout(repoPath, templateName); err != nil {
 			return "", fmt.Errorf("failed to clone template: %w", err)
 		}
+	default:
+		// No git, or a cache imported from a bundle or downloaded as an
+		// archive, which a clone would replace: download the repository
+		// archive and keep only this template
+		label := fmt.Sprintf("Fetching template %s", templateName)
+		if err := fetchArchive(KindTemplate, repoPath, label, templateName); err != nil {
+			return "", fmt.Errorf("failed to fetch template: %w", err)
+		}
+	}
+
+	if _, err := os.Stat(templatePath); err != nil {
+		return "", fmt.Errorf("template %q not found in %s", templateName, source(KindTemplate).CloneURL())
 	}
 
 	// template.yaml is required; if missing, create a minimal one for compatibility
@@ -55,6 +94,8 @@ func Resolve(templateName string) (string, error) {
 		}
 	}
 
+	recordUpdate(baseCache)
+	recordUse(baseCache, templateName)
 	return templatePath, nil
 }
 
@@ -95,82 +136,112 @@ func cloneWithSparseCheckout(repoPath, templateName string) error {
 		return err
 	}
 
-	// Remove partial/corrupt clone if exists
-	os.RemoveAll(repoPath)
-
 	// Clone with sparse checkout (fetches only the chosen folder)
-	cloneCmd := exec.Command("git", "clone",
-		"--depth", "1",
-		"--filter=blob:none",
-		"--sparse",
-		repoURL,
-		repoPath,
-	)
-	cloneCmd.Stdout = os.Stdout
-	cloneCmd.Stderr = os.Stderr
-	if err := cloneCmd.Run(); err != nil {
-		return fmt.Errorf("git clone: %w", err)
+	label := fmt.Sprintf("Fetching template %s", templateName)
+	if err := runGit("", label, cloneArgs(KindTemplate, repoPath)...); err != nil {
+		return err
 	}
 
 	// Checkout only the template folder (cone mode includes the dir and its contents)
-	return runInDir(repoPath, "git", "sparse-checkout", "set", templateName)
+	return runGit(repoPath, label, "sparse-checkout", "set", templateName)
 }
 
 func addTemplateToSparseCheckout(repoPath, templateName string) error {
 	// Add template folder to sparse checkout
-	addCmd := exec.Command("git", "sparse-checkout", "add", templateName)
-	addCmd.Dir = repoPath
-	addCmd.Stdout = os.Stdout
-	addCmd.Stderr = os.Stderr
-	if err := addCmd.Run(); err != nil {
-		return fmt.Errorf("git sparse-checkout add: %w", err)
+	label := fmt.Sprintf("Fetching template %s", templateName)
+	if err := runGit(repoPath, label, "sparse-checkout", "add", templateName); err != nil {
+		return err
 	}
 
-	// Pull latest
-	pullCmd := exec.Command("git", "pull")
-	pullCmd.Dir = repoPath
-	pullCmd.Stdout = os.Stdout
-	pullCmd.Stderr = os.Stderr
-	if err := pullCmd.Run(); err != nil {
-		return fmt.Errorf("git pull: %w", err)
+	// Pull latest, unless --no-refresh (the new folder is then checked out
+	// at the commit already in the cache)
+	if refresh == RefreshNever {
+		return nil
+	}
+	return runGit(repoPath, label, "pull")
+}
+
+// Checkout returns the git checkout of the cache of kind, cloning it when
+// missing (with only the root files checked out) and pulling it when stale.
+// Registries of type git list the repository from it.
+func Checkout(kind string) (string, error) {
+	repoPath, err := RepoPath(kind)
+	if err != nil {
+		return "", err
+	}
+	if isGitRepo(repoPath) {
+		if err := refreshIfStale(kind); err != nil {
+			return "", err
+		}
+		return repoPath, nil
+	}
+	if offline {
+		return "", fmt.Errorf("%s repository %w in %s (offline mode)", kind, ErrNotCached, repoPath)
 	}
 
-	return nil
+	unlock, err := LockCache(kind)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if isGitRepo(repoPath) {
+		return repoPath, nil
+	}
+	if isPlainCache(repoPath) {
+		return "", plainCacheError(kind, repoPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(repoPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := runGit("", fmt.Sprintf("Fetching %s repository", kind), cloneArgs(kind, repoPath)...); err != nil {
+		return "", err
+	}
+	recordUpdate(filepath.Dir(repoPath))
+	return repoPath, nil
 }
 
-func runInDir(dir, name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Dir = dir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// cloneArgs returns the arguments of a shallow, blobless, sparse clone of
+// the repository of kind, at its configured ref, into dir.
+func cloneArgs(kind, dir string) []string {
+	args := []string{"clone", "--depth", "1", "--filter=blob:none", "--sparse"}
+	if ref := sourceRef(kind); ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	return append(args, source(kind).CloneURL(), dir)
 }
 
-// TemplatesRepoPath returns the path to the cached templates repo (~/.cache/cosmos/templates/_repo).
+// TemplatesRepoPath returns the path to the cached templates repo (<cache>/templates/_repo).
 func TemplatesRepoPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	root, err := CacheDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(homeDir, cacheDir, repoDir), nil
+	return filepath.Join(root, templatesCacheDir, repoDir), nil
 }
 
-// PullTemplatesRepo runs git pull in the templates cache if the repo exists.
-// It returns (true, nil) when pull ran, (false, nil) when no cache exists, or (_, err) on failure.
+// PullTemplatesRepo updates the templates cache (git pull, or a new archive
+// download) if it exists. It returns (true, nil) when it was updated, (false,
+// nil) when no cache exists or it was imported from a bundle, or (_, err) on
+// failure.
 func PullTemplatesRepo() (updated bool, err error) {
 	repoPath, err := TemplatesRepoPath()
 	if err != nil {
 		return false, err
 	}
-	if !isGitRepo(repoPath) {
+	if !canPull(repoPath) {
 		return false, nil
 	}
-	pullCmd := exec.Command("git", "pull")
-	pullCmd.Dir = repoPath
-	pullCmd.Stdout = os.Stdout
-	pullCmd.Stderr = os.Stderr
-	if err := pullCmd.Run(); err != nil {
-		return false, fmt.Errorf("templates: git pull: %w", err)
+	if offline {
+		return false, fmt.Errorf("templates: git pull %w", ErrOffline)
+	}
+	unlock, err := LockCache(KindTemplate)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if err := pullRepo(KindTemplate, repoPath, "Updating templates cache"); err != nil {
+		return false, fmt.Errorf("templates: %w", err)
 	}
+	recordUpdate(filepath.Dir(repoPath))
 	return true, nil
 }
@@ -0,0 +1,315 @@
+// Package scaffold creates template directories: a new template to start
+// from, or a template converted from an existing Go project.
+package scaffold
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cosmos-toolkit/cli/internal/writer"
+)
+
+// CasesDir is where the scaffolds keep the answers files of
+// cosmos template test. Templates exclude it from generation.
+const CasesDir = "cases"
+
+// file is a file of a new template.
+type file struct {
+	path, content string
+}
+
+// newTemplateFiles are the files of a new template; %[1]s is its name.
+var newTemplateFiles = []file{
+	{"template.yaml", `name: %[1]s
+version: 0.1.0
+# Types of cosmos init this template serves (api, worker, cli); all when empty.
+types: []
+defaults:
+  goVersion: "1.23"
+# Values templates read as .Values.<key>; answers come from init --answers,
+# else the prompt's default.
+prompts:
+  - key: description
+    description: "One-line description of the project"
+    default: "A new Go project"
+  - key: docker
+    description: "Add a Dockerfile"
+    type: bool
+    default: false
+features:
+  - docker
+files:
+  engine: gotmpl
+  exclude: ["` + CasesDir + `"]
+`},
+	{"go.mod.tmpl", `module {{.Module}}
+
+go {{.GoVersion}}
+`},
+	{"cmd/{{.ProjectName}}/main.go.tmpl", `{{template "header" .}}
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println({{quote .Values.description}})
+}
+`},
+	{"_partials/header.tmpl", `// {{.ProjectName}}: {{.Values.description}}
+`},
+	{"{{if .Values.docker}}deploy{{end}}/Dockerfile.tmpl", `FROM golang:{{.GoVersion}} AS build
+WORKDIR /src
+COPY . .
+RUN go build -o /out/{{.ProjectName}} ./cmd/{{.ProjectName}}
+
+FROM gcr.io/distroless/static
+COPY --from=build /out/{{.ProjectName}} /{{.ProjectName}}
+ENTRYPOINT ["/{{.ProjectName}}"]
+`},
+	{"README.md.tmpl", `# {{.ProjectName}}
+
+{{.Values.description}}
+`},
+	{CasesDir + "/default.yaml", `# Answers for cosmos template test (and cosmos init --answers).
+projectName: demo
+module: example.com/demo
+description: "Demo project"
+docker: true
+`},
+}
+
+// New writes a new template named name into dir, which must not exist.
+// It returns the files written.
+func New(dir, name string) ([]string, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("%s already exists", dir)
+	}
+	var written []string
+	for _, f := range newTemplateFiles {
+		content := f.content
+		if f.path == "template.yaml" {
+			content = fmt.Sprintf(content, name)
+		}
+		if err := writer.WriteFile(filepath.Join(dir, filepath.FromSlash(f.path)), []byte(content), 0644); err != nil {
+			return nil, err
+		}
+		written = append(written, f.path)
+	}
+	return written, nil
+}
+
+// Conversion is the result of FromProject.
+type Conversion struct {
+	Module      string `json:"module" yaml:"module"`
+	ProjectName string `json:"projectName" yaml:"projectName"`
+	// Templates are the files that became .tmpl files, Copied the others
+	// (by path in the template).
+	Templates []string `json:"templates" yaml:"templates"`
+	Copied    []string `json:"copied" yaml:"copied"`
+	// Verbatim are files already named .tmpl, which the template copies as
+	// they are.
+	Verbatim []string `json:"verbatim,omitempty" yaml:"verbatim,omitempty"`
+}
+
+// FromProject converts the Go project in src into a template named name in
+// dest, which must not exist. Its module path becomes {{.Module}} in every
+// text file, and its name (the last element of the module path)
+// {{.ProjectName}} in the directory cmd/<name> and, as a word, in text
+// files other than Go sources, go.mod and go.sum. Files that change get the
+// .tmpl suffix, with the {{ }} they already contained escaped.
+func FromProject(src, dest, name string) (*Conversion, error) {
+	module, goVersion, err := readGoMod(filepath.Join(src, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return nil, fmt.Errorf("%s already exists", dest)
+	}
+	c := &Conversion{Module: module, ProjectName: path.Base(module)}
+
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel == "template.yaml" {
+			return fmt.Errorf("%s already has a template.yaml", src)
+		}
+
+		out := c.templatePath(rel)
+		target := filepath.Join(dest, filepath.FromSlash(out))
+		if d.Type()&fs.ModeSymlink != 0 {
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			c.Copied = append(c.Copied, out)
+			return writer.WriteSymlink(target, link)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		converted := string(data)
+		if !writer.IsBinary(data) && !strings.HasSuffix(rel, ".tmpl") {
+			converted = escapeActions(converted)
+			converted = strings.ReplaceAll(converted, module, "{{.Module}}")
+			if base := path.Base(rel); path.Ext(base) != ".go" && base != "go.mod" && base != "go.sum" {
+				converted = replaceWord(converted, c.ProjectName, "{{.ProjectName}}")
+			}
+		}
+		switch {
+		case strings.HasSuffix(rel, ".tmpl"):
+			c.Verbatim = append(c.Verbatim, out)
+		case converted != escapeActions(string(data)):
+			out += ".tmpl"
+			target += ".tmpl"
+			data = []byte(converted)
+			c.Templates = append(c.Templates, out)
+		default:
+			c.Copied = append(c.Copied, out)
+		}
+		return writer.WriteFile(target, data, info.Mode().Perm())
+	})
+	if err != nil {
+		_ = os.RemoveAll(dest)
+		return nil, err
+	}
+
+	if err := writer.WriteFile(filepath.Join(dest, "template.yaml"), []byte(c.templateYAML(name, goVersion)), 0644); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// templatePath is the path in the template of the project file rel:
+// cmd/<name> becomes cmd/{{.ProjectName}}. Other directories named after
+// the project keep their name, which import paths use.
+func (c *Conversion) templatePath(rel string) string {
+	if rest, ok := strings.CutPrefix(rel, "cmd/"+c.ProjectName+"/"); ok {
+		return "cmd/{{.ProjectName}}/" + rest
+	}
+	return rel
+}
+
+// replaceWord replaces the occurrences of word in s that are not part of a
+// longer name: not next to a letter, digit, _ or -, nor after a dot
+// (orders, ./cmd/orders and "orders" are replaced; orders-client,
+// myorders and x.orders are not).
+func replaceWord(s, word, repl string) string {
+	isNamePart := func(c byte) bool {
+		return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, word)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := i + len(word)
+		if (i > 0 && (isNamePart(s[i-1]) || s[i-1] == '.')) || (end < len(s) && isNamePart(s[end])) {
+			b.WriteString(s[:end])
+		} else {
+			b.WriteString(s[:i])
+			b.WriteString(repl)
+		}
+		s = s[end:]
+	}
+}
+
+func (c *Conversion) templateYAML(name, goVersion string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %s\nversion: 0.1.0\n", name)
+	fmt.Fprintf(&b, "# Converted from %s.\n", c.Module)
+	if goVersion != "" {
+		fmt.Fprintf(&b, "defaults:\n  goVersion: %q\n", goVersion)
+	}
+	b.WriteString("prompts: []\nfeatures: []\nfiles:\n  engine: gotmpl\n")
+	if len(c.Verbatim) > 0 {
+		sort.Strings(c.Verbatim)
+		b.WriteString("  # Already .tmpl files in the project: copied as they are\n  verbatim:\n")
+		for _, v := range c.Verbatim {
+			fmt.Fprintf(&b, "    - %q\n", v)
+		}
+	}
+	return b.String()
+}
+
+// escapeActions makes the {{ and }} of s literal text of a Go template.
+func escapeActions(s string) string {
+	if !strings.Contains(s, "{{") && !strings.Contains(s, "}}") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "{{"):
+			b.WriteString(`{{"{{"}}`)
+			i++
+		case strings.HasPrefix(s[i:], "}}"):
+			b.WriteString(`{{"}}"}}`)
+			i++
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// readGoMod returns the module path and go version of the go.mod file.
+func readGoMod(file string) (module, goVersion string, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", "", fmt.Errorf("%s not found: not a Go module", file)
+		}
+		return "", "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "module":
+			module = strings.Trim(fields[1], `"`)
+		case "go":
+			goVersion = fields[1]
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", "", err
+	}
+	if module == "" {
+		return "", "", fmt.Errorf("%s declares no module", file)
+	}
+	return module, goVersion, nil
+}
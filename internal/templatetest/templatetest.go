@@ -0,0 +1,219 @@
+// Package templatetest checks the projects a template generates: that they
+// build, pass go vet and their tests, and match golden snapshots.
+package templatetest
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cosmos-toolkit/cli/internal/hooks"
+	"github.com/cosmos-toolkit/cli/internal/writer"
+)
+
+// Status is the outcome of one step of a case.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Steps of a case, in order.
+const (
+	StepRender = "render"
+	StepBuild  = "build"
+	StepVet    = "vet"
+	StepTest   = "test"
+	StepGolden = "golden"
+)
+
+// Step is the result of one step. Output holds the last lines a failed
+// step printed, or the differences from the golden snapshot.
+type Step struct {
+	Name       string   `json:"name" yaml:"name"`
+	Status     Status   `json:"status" yaml:"status"`
+	DurationMS int64    `json:"durationMs" yaml:"durationMs"`
+	Error      string   `json:"error,omitempty" yaml:"error,omitempty"`
+	Output     []string `json:"output,omitempty" yaml:"output,omitempty"`
+}
+
+// GoChecks runs go build, go vet and go test on every package of the
+// module in dir, with env as the environment. After a failure the
+// remaining steps are skipped.
+func GoChecks(dir string, env []string) []Step {
+	var steps []Step
+	failed := false
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{StepBuild, []string{"build", "./..."}},
+		{StepVet, []string{"vet", "./..."}},
+		{StepTest, []string{"test", "./..."}},
+	} {
+		if failed {
+			steps = append(steps, Step{Name: c.name, Status: Skip})
+			continue
+		}
+		step := runGo(c.name, dir, env, c.args)
+		failed = step.Status == Fail
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func runGo(name, dir string, env []string, args []string) Step {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	start := time.Now()
+	err := cmd.Run()
+	step := Step{Name: name, Status: Pass, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		step.Status = Fail
+		step.Error = fmt.Sprintf("go %s: %v", strings.Join(args, " "), err)
+		step.Output = hooks.LastLines(out.String())
+	}
+	return step
+}
+
+// Compare returns how the tree at dir differs from the golden tree: files
+// missing from either, files whose content (or symlink target) differs,
+// with the first line that differs. .git directories are ignored.
+func Compare(dir, golden string) ([]string, error) {
+	got, err := readTree(dir)
+	if err != nil {
+		return nil, err
+	}
+	want, err := readTree(golden)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	for _, name := range sortedKeys(want) {
+		g, ok := got[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s: in the golden tree but not generated", name))
+		case g != want[name]:
+			diffs = append(diffs, fmt.Sprintf("%s: %s", name, firstDifference(g, want[name])))
+		}
+	}
+	for _, name := range sortedKeys(got) {
+		if _, ok := want[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: generated but not in the golden tree", name))
+		}
+	}
+	return diffs, nil
+}
+
+// Update replaces the golden tree with a copy of the tree at dir.
+func Update(dir, golden string) error {
+	if err := os.RemoveAll(golden); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", golden, err)
+	}
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(golden, rel)
+		switch {
+		case d.IsDir() && d.Name() == ".git":
+			return filepath.SkipDir
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return writer.WriteSymlink(target, link)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return writer.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// readTree returns the content of each file under dir, by slash-separated
+// path; a symlink's content is "-> target".
+func readTree(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = "-> " + link
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	return files, err
+}
+
+// firstDifference describes where got first differs from want.
+func firstDifference(got, want string) string {
+	if writer.IsBinary([]byte(got)) || writer.IsBinary([]byte(want)) {
+		return "binary content differs"
+	}
+	g, w := strings.Split(got, "\n"), strings.Split(want, "\n")
+	i := 0
+	for ; i < len(g) && i < len(w); i++ {
+		if g[i] != w[i] {
+			return fmt.Sprintf("line %d: got %q, want %q", i+1, g[i], w[i])
+		}
+	}
+	return fmt.Sprintf("differs at the end of line %d (got %d lines, want %d)", i, len(g), len(w))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,19 +1,32 @@
 package writer
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
-func WriteFile(path string, data []byte) error {
+// WriteFile writes data to path with permissions perm, also when the file
+// already exists.
+func WriteFile(path string, data []byte, perm fs.FileMode) error {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	file, err := os.Create(path)
+	// A symlink at path is replaced, not written through
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to replace symlink: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
@@ -23,9 +36,119 @@ func WriteFile(path string, data []byte) error {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
+	// OpenFile only applies perm to new files
+	if err := file.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+
+	return nil
+}
+
+// WriteSymlink creates a symbolic link at path pointing to target,
+// replacing any file there.
+func WriteSymlink(path, target string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	if err := os.Symlink(target, path); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	return nil
+}
+
+// LinkInside reports whether the symlink name (a slash-separated path
+// relative to a root directory) with target stays inside the root: target
+// is relative and does not climb out of it.
+func LinkInside(name, target string) bool {
+	if target == "" || path.IsAbs(target) || filepath.IsAbs(target) || filepath.VolumeName(target) != "" {
+		return false
+	}
+	resolved := path.Join(path.Dir(name), filepath.ToSlash(target))
+	return resolved != ".." && !strings.HasPrefix(resolved, "../")
+}
+
+// CheckNoSymlinks returns an error if path, or a directory between root
+// and path, is a symlink. Archives are extracted with it so that an entry
+// is never written through a symlink an earlier entry created. Components
+// that do not exist yet are fine.
+func CheckNoSymlinks(root, path string) error {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside %s", path, root)
+	}
+	if rel == "." {
+		return nil
+	}
+	cur := root
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, elem)
+		info, err := os.Lstat(cur)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", cur)
+		}
+	}
+	return nil
+}
+
+// CheckInside returns an error unless the directory of path, with its
+// symlinks resolved, is under root: writing path then cannot land outside
+// root through a symlinked directory.
+func CheckInside(root, path string) error {
+	if _, err := os.Lstat(root); os.IsNotExist(err) {
+		// Nothing below root exists yet
+		return nil
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	// The deepest existing directory decides; the rest is created by
+	// MkdirAll and holds no symlink
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	rel, err := filepath.Rel(realRoot, realDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside %s through a symlink", path, root)
+	}
 	return nil
 }
 
+// binarySniffLen is how much of a file IsBinary looks at, as git does.
+const binarySniffLen = 8000
+
+// IsBinary reports whether data looks like binary content (a NUL byte in
+// its first 8000 bytes), which must not go through text replacement.
+func IsBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
 func WriteFromReader(path string, reader io.Reader) error {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {